@@ -0,0 +1,615 @@
+package jsonapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// ErrInvalidOperation is returned by UnmarshalOperations when an operation of
+// an Atomic Operations request document is malformed.
+var ErrInvalidOperation = errors.New("invalid operation")
+
+// OperationCode is the "op" member of an operation, naming what it does.
+type OperationCode string
+
+const (
+	// OperationAdd creates a resource, or adds members to a to-many
+	// relationship.
+	OperationAdd OperationCode = "add"
+	// OperationUpdate updates a resource, or replaces a relationship.
+	OperationUpdate OperationCode = "update"
+	// OperationRemove deletes a resource, or removes members from a to-many
+	// relationship.
+	OperationRemove OperationCode = "remove"
+)
+
+var (
+	// atomicDocumentMembers are the top-level members of an Atomic Operations
+	// request document, which must not hold "data" or "included".
+	atomicDocumentMembers = map[string]bool{
+		"atomic:operations": true,
+		"links":             true,
+		"meta":              true,
+		"jsonapi":           true,
+	}
+
+	// operationMembers are the members of an operation object.
+	operationMembers = map[string]bool{
+		"op":   true,
+		"ref":  true,
+		"href": true,
+		"data": true,
+		"meta": true,
+	}
+
+	// refMembers are the members of the "ref" object of an operation.
+	refMembers = map[string]bool{
+		"type":         true,
+		"id":           true,
+		"lid":          true,
+		"relationship": true,
+	}
+)
+
+// Ref is the "ref" member of an operation, identifying the resource, or the
+// relationship of a resource, that the operation targets.
+type Ref struct {
+	Type         string `json:"type"`
+	ID           string `json:"id,omitempty"`
+	Lid          string `json:"lid,omitempty"`
+	Relationship string `json:"relationship,omitempty"`
+}
+
+// Operation is a single operation of an Atomic Operations request document,
+// as returned by UnmarshalOperations. Its "data" is decoded into a model with
+// Unmarshal, or UnmarshalRelationship for operations on a relationship.
+type Operation struct {
+	Op   OperationCode
+	Ref  *Ref
+	Href string
+	Meta *Meta
+
+	// data holds the decoded "data" member, with the local IDs it refers to
+	// resolved, and hasData whether the member was present at all.
+	data    interface{}
+	hasData bool
+
+	index int
+	opts  unmarshalOptions
+}
+
+// Pointer returns the JSON Pointer to the operation in the request document,
+// e.g. `/atomic:operations/2`.
+func (o *Operation) Pointer() string {
+	return jsonPointer("/atomic:operations", strconv.Itoa(o.index))
+}
+
+// Type returns the type of the resource the operation targets, from its "ref"
+// or else from its "data".
+func (o *Operation) Type() string {
+	if o.Ref != nil {
+		return o.Ref.Type
+	}
+	if resource, ok := o.data.(map[string]interface{}); ok {
+		t, _ := resource["type"].(string)
+		return t
+	}
+	return ""
+}
+
+// IsRelationship reports whether the operation targets a relationship rather
+// than a resource, in which case its "data" is resource linkage.
+func (o *Operation) IsRelationship() bool {
+	if o.Ref != nil {
+		return o.Ref.Relationship != ""
+	}
+	return strings.Contains(o.Href, "/relationships/")
+}
+
+// Unmarshal decodes the resource object in the operation's "data" into
+// model, as UnmarshalPayload does, with errors pointing into the operation.
+// For an operation without "data", such as a remove, only the ID from its
+// "ref" is set on model. The access rules of model's fields are enforced for
+// the operation's code, as with ForOperation.
+//
+// model interface{} should be a pointer to a struct.
+func (o *Operation) Unmarshal(model interface{}) error {
+	d := &decoder{opts: o.opts}
+	if d.opts.operation == "" {
+		d.opts.operation = o.Op
+	}
+	if err := d.index(nil); err != nil {
+		return err
+	}
+
+	if !o.hasData {
+		if o.Ref == nil {
+			return newOperationError(fmt.Errorf("%w: missing \"ref\"", ErrInvalidOperation), o.Pointer())
+		}
+		ref := &Node{Type: o.Ref.Type, ID: o.Ref.ID}
+		if err := d.unmarshalResource(ref, reflect.ValueOf(model), resourceAt(jsonPointer(o.Pointer(), "ref"))); err != nil {
+			return err
+		}
+		return d.err()
+	}
+
+	pointer := jsonPointer(o.Pointer(), "data")
+	if _, ok := o.data.(map[string]interface{}); !ok {
+		return newOperationError(fmt.Errorf("%w: expected a resource object", ErrInvalidOperation), pointer)
+	}
+
+	raw, err := json.Marshal(o.data)
+	if err != nil {
+		return err
+	}
+	if d.opts.disallowUnknownMembers {
+		if err := d.checkResourceMembers(raw, pointer); err != nil {
+			return err
+		}
+	}
+
+	node := new(Node)
+	if err := newNumberDecoder(bytes.NewReader(raw)).Decode(node); err != nil {
+		return err
+	}
+
+	if err := d.unmarshalPrimary(node, reflect.ValueOf(model), resourceAt(pointer)); err != nil {
+		return err
+	}
+	return d.err()
+}
+
+// UnmarshalRelationship decodes the resource linkage in the "data" of an
+// operation on a relationship into that relationship's field of model, as
+// the package-level UnmarshalRelationship does, and sets the ID of model
+// from the operation's "ref".
+//
+// model interface{} should be a pointer to a struct.
+func (o *Operation) UnmarshalRelationship(model interface{}) error {
+	if o.Ref == nil || o.Ref.Relationship == "" {
+		return newOperationError(fmt.Errorf("%w: expected a \"ref\" to a relationship", ErrInvalidOperation), o.Pointer())
+	}
+
+	value := reflect.ValueOf(model)
+	if value.Kind() != reflect.Ptr || value.Elem().Kind() != reflect.Struct {
+		return ErrUnexpectedType
+	}
+
+	d := &decoder{opts: o.opts}
+	if err := d.index(nil); err != nil {
+		return err
+	}
+
+	ref := &Node{Type: o.Ref.Type, ID: o.Ref.ID}
+	if err := d.unmarshalResource(ref, value, resourceAt(jsonPointer(o.Pointer(), "ref"))); err != nil {
+		return err
+	}
+
+	relationship := map[string]interface{}{}
+	if o.hasData {
+		relationship["data"] = o.data
+	}
+	if err := d.unmarshalLinkage(relationship, o.Pointer(), value, o.Ref.Relationship); err != nil {
+		return err
+	}
+	return d.err()
+}
+
+// rawOperation is an operation object as it appears in the request document.
+type rawOperation struct {
+	Op   OperationCode   `json:"op"`
+	Ref  *Ref            `json:"ref"`
+	Href string          `json:"href"`
+	Data json.RawMessage `json:"data"`
+	Meta *Meta           `json:"meta"`
+}
+
+// UnmarshalOperations decodes an Atomic Operations request document, as
+// defined by the https://jsonapi.org/ext/atomic extension, into its
+// operations, in order.
+//
+// Resources added by an operation may be identified by a local ID ("lid"),
+// for later operations to refer to in their "ref" or in resource linkage.
+// Each such resource is given an ID from generator, and the lids are
+// returned mapped to the generated IDs. A lid must be defined by an earlier
+// operation than those referring to it; otherwise, or if it is defined twice,
+// an *UnmarshalError wrapping ErrUndefinedLid or ErrDuplicateLid is
+// returned. With a nil generator lids are used as IDs, as UnmarshalPayload
+// does.
+//
+//	ops, _, err := jsonapi.UnmarshalOperations(r.Body, generator)
+//	if err != nil {
+//		// ...report the error...
+//	}
+//	results, err := jsonapi.ExecuteOperations(store.Begin(), ops)
+//
+// Malformed operations are reported as an *UnmarshalError wrapping
+// ErrInvalidOperation, pointing at the operation, e.g.
+// `/atomic:operations/1/op`.
+func UnmarshalOperations(in io.Reader, generator IDGenerator, opts ...UnmarshalOption) ([]*Operation, map[string]string, error) {
+	d := newDecoder(opts)
+	if generator != nil {
+		d.lids = &lidResolver{generator: generator, ids: LidMap{}}
+	}
+
+	ops, err := d.unmarshalOperations(in)
+	if err != nil {
+		return nil, d.lidMap(), err
+	}
+	return ops, d.lidMap(), d.err()
+}
+
+func (d *decoder) unmarshalOperations(in io.Reader) ([]*Operation, error) {
+	var document map[string]json.RawMessage
+	if err := json.NewDecoder(in).Decode(&document); err != nil {
+		return nil, err
+	}
+
+	if d.opts.disallowUnknownMembers {
+		if err := checkMembers(d, document, atomicDocumentMembers, ""); err != nil {
+			return nil, err
+		}
+	}
+
+	var raws []json.RawMessage
+	if err := json.Unmarshal(document["atomic:operations"], &raws); err != nil || raws == nil {
+		return nil, newOperationError(fmt.Errorf("%w: expected an \"atomic:operations\" array", ErrInvalidOperation), "/atomic:operations")
+	}
+
+	ops := make([]*Operation, 0, len(raws))
+	for i, raw := range raws {
+		op, err := d.unmarshalOperation(raw, i)
+		if err != nil {
+			return nil, err
+		}
+		if op != nil {
+			ops = append(ops, op)
+		}
+	}
+	return ops, nil
+}
+
+// unmarshalOperation decodes the i-th operation of the document, resolving
+// the lids it refers to and defining the lid of the resource it adds.
+func (d *decoder) unmarshalOperation(raw json.RawMessage, i int) (*Operation, error) {
+	op := &Operation{index: i, opts: d.opts}
+	pointer := op.Pointer()
+
+	var members map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &members); err != nil {
+		return nil, d.report(newOperationError(fmt.Errorf("%w: expected an object", ErrInvalidOperation), pointer))
+	}
+	if d.opts.disallowUnknownMembers {
+		if err := checkMembers(d, members, operationMembers, pointer); err != nil {
+			return nil, err
+		}
+		var ref map[string]json.RawMessage
+		if json.Unmarshal(members["ref"], &ref) == nil {
+			if err := checkMembers(d, ref, refMembers, jsonPointer(pointer, "ref")); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	var ro rawOperation
+	if err := newNumberDecoder(bytes.NewReader(raw)).Decode(&ro); err != nil {
+		return nil, d.report(newOperationError(fmt.Errorf("Could not unmarshal json: %w", err), pointer))
+	}
+
+	op.Op, op.Ref, op.Href, op.Meta = ro.Op, ro.Ref, ro.Href, unmarshalMeta(ro.Meta)
+	if ro.Data != nil {
+		op.hasData = true
+		if err := newNumberDecoder(bytes.NewReader(ro.Data)).Decode(&op.data); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := d.checkOperation(op); err != nil {
+		return nil, err
+	}
+	if err := d.resolveOperationLids(op); err != nil {
+		return nil, err
+	}
+	return op, nil
+}
+
+// checkOperation reports the ways in which op does not follow the extension.
+func (d *decoder) checkOperation(op *Operation) error {
+	pointer := op.Pointer()
+
+	var errs []error
+	switch op.Op {
+	case OperationAdd, OperationUpdate, OperationRemove:
+	default:
+		errs = append(errs, newOperationError(fmt.Errorf("%w: unknown op %q", ErrInvalidOperation, op.Op), jsonPointer(pointer, "op")))
+	}
+
+	if op.Ref != nil && op.Href != "" {
+		errs = append(errs, newOperationError(fmt.Errorf("%w: \"ref\" and \"href\" cannot both be given", ErrInvalidOperation), pointer))
+	}
+	if op.Ref != nil {
+		if op.Ref.Type == "" {
+			errs = append(errs, newOperationError(fmt.Errorf("%w: missing type", ErrInvalidOperation), jsonPointer(pointer, "ref", "type")))
+		}
+		if op.Ref.ID == "" && op.Ref.Lid == "" && op.Op != OperationAdd {
+			errs = append(errs, newOperationError(fmt.Errorf("%w: missing id or lid", ErrInvalidOperation), jsonPointer(pointer, "ref")))
+		}
+	}
+
+	if op.Op == OperationRemove && !op.IsRelationship() {
+		if op.Ref == nil && op.Href == "" {
+			errs = append(errs, newOperationError(fmt.Errorf("%w: missing \"ref\" or \"href\"", ErrInvalidOperation), pointer))
+		}
+	} else if !op.hasData {
+		errs = append(errs, newOperationError(fmt.Errorf("%w: missing \"data\"", ErrInvalidOperation), pointer))
+	}
+
+	for _, err := range errs {
+		if err := d.report(err); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// resolveOperationLids replaces the lids that op refers to by the IDs they
+// were given by earlier operations, then gives an ID to the resource op adds.
+func (d *decoder) resolveOperationLids(op *Operation) error {
+	pointer := op.Pointer()
+
+	if op.Ref != nil && op.Ref.ID == "" && op.Ref.Lid != "" {
+		ref := &Node{Type: op.Ref.Type, Lid: op.Ref.Lid}
+		if err := d.resolveLid(ref, resourceAt(jsonPointer(pointer, "ref"))); err != nil {
+			return d.report(err)
+		}
+		op.Ref.ID = ref.ID
+	}
+
+	dataPointer := jsonPointer(pointer, "data")
+	if op.IsRelationship() {
+		return d.resolveLinkageLids(op.data, dataPointer)
+	}
+
+	resource, ok := op.data.(map[string]interface{})
+	if !ok {
+		return nil
+	}
+
+	if relationships, ok := resource["relationships"].(map[string]interface{}); ok {
+		for _, name := range sortedKeys(relationships) {
+			relationship, ok := relationships[name].(map[string]interface{})
+			if !ok {
+				continue
+			}
+			relPointer := jsonPointer(dataPointer, "relationships", name, "data")
+			if err := d.resolveLinkageLids(relationship["data"], relPointer); err != nil {
+				return err
+			}
+		}
+	}
+
+	if op.Op != OperationAdd {
+		return d.resolveIdentifierLid(resource, dataPointer)
+	}
+	if d.lids == nil {
+		return nil
+	}
+
+	n := &Node{Type: op.Type()}
+	n.ID, _ = resource["id"].(string)
+	n.Lid, _ = resource["lid"].(string)
+	if err := d.defineLid(n, dataPointer); err != nil {
+		return err
+	}
+	if n.ID != "" {
+		resource["id"] = n.ID
+	}
+	return nil
+}
+
+// resolveLinkageLids resolves the lids of the resource identifier, or array
+// of resource identifiers, found at pointer.
+func (d *decoder) resolveLinkageLids(linkage interface{}, pointer string) error {
+	switch linkage := linkage.(type) {
+	case map[string]interface{}:
+		return d.resolveIdentifierLid(linkage, pointer)
+	case []interface{}:
+		for j, item := range linkage {
+			identifier, ok := item.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			if err := d.resolveIdentifierLid(identifier, jsonPointer(pointer, strconv.Itoa(j))); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// resolveIdentifierLid sets the "id" of the resource identifier found at
+// pointer to the ID given to its lid.
+func (d *decoder) resolveIdentifierLid(identifier map[string]interface{}, pointer string) error {
+	lid, _ := identifier["lid"].(string)
+	if _, hasID := identifier["id"]; lid == "" || hasID {
+		return nil
+	}
+
+	n := &Node{Lid: lid}
+	n.Type, _ = identifier["type"].(string)
+	if err := d.resolveLid(n, resourceAt(pointer)); err != nil {
+		return d.report(err)
+	}
+	identifier["id"] = n.ID
+	return nil
+}
+
+// lidMap returns the lids resolved so far, or nil outside of lid mode.
+func (d *decoder) lidMap() map[string]string {
+	if d.lids == nil {
+		return nil
+	}
+	return d.lids.ids
+}
+
+func newOperationError(err error, pointer string) error {
+	return &UnmarshalError{
+		Pointer: pointer,
+		Status:  http.StatusBadRequest,
+		Err:     err,
+	}
+}
+
+// OperationExecutor applies the operations of an Atomic Operations request
+// for ExecuteOperations. Since the extension requires that either all of the
+// operations or none of them are applied, implementations will typically
+// apply them within a single database transaction, begun when the executor
+// is created.
+type OperationExecutor interface {
+	// Apply performs op, returning the model to report as its result, or
+	// nil if there is none. Returning an *ErrorObject controls how the
+	// failure is reported to the client.
+	Apply(op *Operation) (interface{}, error)
+	// Commit is called once every operation has been applied.
+	Commit() error
+	// Rollback is called instead of Commit when an operation fails.
+	Rollback() error
+}
+
+// ExecuteOperations applies ops in order through x, committing if all of
+// them succeed and rolling back at the first failure, which is returned as
+// an *OperationError. The results are returned in the order of ops, ready to
+// be passed to MarshalOperationResults.
+//
+//	results, err := jsonapi.ExecuteOperations(tx, ops)
+//	var opErr *jsonapi.OperationError
+//	if errors.As(err, &opErr) {
+//		jsonapi.MarshalErrors(w, opErr.ErrorObjects())
+//		return
+//	}
+func ExecuteOperations(x OperationExecutor, ops []*Operation) ([]interface{}, error) {
+	results := make([]interface{}, len(ops))
+
+	for i, op := range ops {
+		result, err := x.Apply(op)
+		if err != nil {
+			opErr := &OperationError{Index: op.index, Err: err}
+			if rbErr := x.Rollback(); rbErr != nil {
+				return nil, fmt.Errorf("%w (rollback failed: %v)", opErr, rbErr)
+			}
+			return nil, opErr
+		}
+		results[i] = result
+	}
+
+	if err := x.Commit(); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// OperationError is returned by ExecuteOperations when an operation fails.
+type OperationError struct {
+	// Index is the position of the operation in "atomic:operations".
+	Index int
+	// Err is the error the OperationExecutor returned.
+	Err error
+}
+
+// Error implements the `Error` interface.
+func (e *OperationError) Error() string {
+	return fmt.Sprintf("operation %d: %s", e.Index, e.Err.Error())
+}
+
+// Unwrap returns the error the OperationExecutor returned.
+func (e *OperationError) Unwrap() error {
+	return e.Err
+}
+
+// ErrorObjects converts the error into JSON API error objects, ready to be
+// passed to `MarshalErrors`. Errors returned by Operation.Unmarshal keep the
+// pointers into the operation they carry; any other error is given a source
+// pointer to the operation, e.g. `/atomic:operations/2`, and is reported
+// with status 500 unless it is an *ErrorObject.
+func (e *OperationError) ErrorObjects() []*ErrorObject {
+	var errs UnmarshalErrors
+	if errors.As(e.Err, &errs) {
+		return errs.ErrorObjects()
+	}
+	var unmarshalErr *UnmarshalError
+	if errors.As(e.Err, &unmarshalErr) {
+		return []*ErrorObject{unmarshalErr.ErrorObject()}
+	}
+
+	pointer := jsonPointer("/atomic:operations", strconv.Itoa(e.Index))
+
+	var obj *ErrorObject
+	if errors.As(e.Err, &obj) {
+		withSource := *obj
+		if withSource.Source == nil {
+			withSource.Source = &ErrorSource{Pointer: pointer}
+		}
+		return []*ErrorObject{&withSource}
+	}
+
+	return []*ErrorObject{{
+		Title:  http.StatusText(http.StatusInternalServerError),
+		Detail: e.Err.Error(),
+		Status: strconv.Itoa(http.StatusInternalServerError),
+		Source: &ErrorSource{Pointer: pointer},
+	}}
+}
+
+// OperationResultsPayload is used to represent an Atomic Operations response
+// document, holding one result for each operation of the request.
+type OperationResultsPayload struct {
+	Results []*OperationResult `json:"atomic:results"`
+}
+
+// OperationResult is used to represent the result of a single operation. It
+// is empty for operations that have no result data.
+type OperationResult struct {
+	Data *Node `json:"data,omitempty"`
+	Meta *Meta `json:"meta,omitempty"`
+}
+
+// MarshalOperationResults writes an Atomic Operations response document
+// holding a result for each of results, in order. A nil result is written as
+// an empty result object, an *OperationResult as it is, and a model as the
+// "data" of its result, with its relationships as resource linkage.
+//
+// Each result should be nil, an *OperationResult or a pointer to a struct.
+func MarshalOperationResults(w io.Writer, results []interface{}, opts ...MarshalOption) error {
+	payload := &OperationResultsPayload{Results: make([]*OperationResult, len(results))}
+
+	for i, result := range results {
+		switch result := result.(type) {
+		case nil:
+			payload.Results[i] = &OperationResult{}
+		case *OperationResult:
+			payload.Results[i] = result
+		default:
+			value := reflect.ValueOf(result)
+			if value.Kind() != reflect.Ptr || value.Elem().Kind() != reflect.Struct {
+				return ErrUnexpectedType
+			}
+
+			// Results have no "included", so related resources are left as
+			// linkage
+			node, err := newEncoder(true, opts).visitModelNode(result)
+			if err != nil {
+				return err
+			}
+			payload.Results[i] = &OperationResult{Data: node}
+		}
+	}
+
+	return json.NewEncoder(w).Encode(payload)
+}
@@ -0,0 +1,264 @@
+package jsonapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+)
+
+const operationsPayload = `{
+	"atomic:operations": [{
+		"op": "add",
+		"data": {
+			"type": "posts",
+			"lid": "new-post",
+			"attributes": {"title": "Atomic"}
+		}
+	}, {
+		"op": "add",
+		"data": {
+			"type": "comments",
+			"lid": "new-comment",
+			"attributes": {"body": "First"}
+		}
+	}, {
+		"op": "update",
+		"ref": {"type": "posts", "lid": "new-post", "relationship": "comments"},
+		"data": [{"type": "comments", "lid": "new-comment"}, {"type": "comments", "id": "7"}]
+	}, {
+		"op": "remove",
+		"ref": {"type": "posts", "id": "3"}
+	}]
+}`
+
+func TestUnmarshalOperations(t *testing.T) {
+	ops, lids, err := UnmarshalOperations(strings.NewReader(operationsPayload), &sequenceGenerator{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ops) != 4 {
+		t.Fatalf("Was expecting 4 operations, got %d", len(ops))
+	}
+	if lids["new-post"] != "100" || lids["new-comment"] != "101" {
+		t.Fatalf("Was expecting generated IDs for both lids, got %v", lids)
+	}
+
+	post := new(Post)
+	if err := ops[0].Unmarshal(post); err != nil {
+		t.Fatal(err)
+	}
+	if post.ID != 100 || post.Title != "Atomic" {
+		t.Fatalf("Was expecting the added post with its generated ID, got %+v", post)
+	}
+
+	if !ops[2].IsRelationship() {
+		t.Fatal("Was expecting an operation on a relationship")
+	}
+	related := new(Post)
+	if err := ops[2].UnmarshalRelationship(related); err != nil {
+		t.Fatal(err)
+	}
+	if related.ID != 100 || len(related.Comments) != 2 || related.Comments[0].ID != 101 || related.Comments[1].ID != 7 {
+		t.Fatalf("Was expecting the comments of the added post, got %+v", related)
+	}
+
+	removed := new(Post)
+	if err := ops[3].Unmarshal(removed); err != nil {
+		t.Fatal(err)
+	}
+	if ops[3].Op != OperationRemove || removed.ID != 3 {
+		t.Fatalf("Was expecting the removed post's ID, got %+v", removed)
+	}
+}
+
+func TestUnmarshalOperations_invalid(t *testing.T) {
+	for _, tc := range []struct {
+		desc     string
+		payload  string
+		expected error
+		pointer  string
+	}{
+		{
+			desc:     "unknownOp",
+			payload:  `{"atomic:operations": [{"op": "upsert", "data": {"type": "posts"}}]}`,
+			expected: ErrInvalidOperation,
+			pointer:  "/atomic:operations/0/op",
+		},
+		{
+			desc:     "missingData",
+			payload:  `{"atomic:operations": [{"op": "add", "ref": {"type": "posts"}}]}`,
+			expected: ErrInvalidOperation,
+			pointer:  "/atomic:operations/0",
+		},
+		{
+			desc:     "refAndHref",
+			payload:  `{"atomic:operations": [{"op": "remove", "ref": {"type": "posts", "id": "1"}, "href": "/posts/1"}]}`,
+			expected: ErrInvalidOperation,
+			pointer:  "/atomic:operations/0",
+		},
+		{
+			desc:     "missingOperations",
+			payload:  `{"data": {"type": "posts"}}`,
+			expected: ErrInvalidOperation,
+			pointer:  "/atomic:operations",
+		},
+		{
+			desc: "lidUsedBeforeDefined",
+			payload: `{"atomic:operations": [
+				{"op": "remove", "ref": {"type": "posts", "lid": "later"}},
+				{"op": "add", "data": {"type": "posts", "lid": "later"}}
+			]}`,
+			expected: ErrUndefinedLid,
+			pointer:  "/atomic:operations/0/ref/lid",
+		},
+		{
+			desc: "undefinedLidInLinkage",
+			payload: `{"atomic:operations": [{"op": "add", "data": {
+				"type": "posts",
+				"relationships": {"latest_comment": {"data": {"type": "comments", "lid": "nope"}}}
+			}}]}`,
+			expected: ErrUndefinedLid,
+			pointer:  "/atomic:operations/0/data/relationships/latest_comment/data/lid",
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			_, _, err := UnmarshalOperations(strings.NewReader(tc.payload), &sequenceGenerator{})
+			if !errors.Is(err, tc.expected) {
+				t.Fatalf("Was expecting %v, got %v", tc.expected, err)
+			}
+			assertUnmarshalErrorPointer(t, err, tc.pointer)
+		})
+	}
+}
+
+func TestOperationUnmarshal_errorPointer(t *testing.T) {
+	payload := `{"atomic:operations": [
+		{"op": "add", "data": {"type": "posts", "attributes": {"title": "Fine"}}},
+		{"op": "add", "data": {"type": "posts", "attributes": {"title": 5}}}
+	]}`
+	ops, _, err := UnmarshalOperations(strings.NewReader(payload), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = ops[1].Unmarshal(new(Post))
+	assertUnmarshalErrorPointer(t, err, "/atomic:operations/1/data/attributes/title")
+}
+
+type fakeExecutor struct {
+	applied    []*Operation
+	fail       func(op *Operation) error
+	committed  bool
+	rolledBack bool
+}
+
+func (x *fakeExecutor) Apply(op *Operation) (interface{}, error) {
+	if x.fail != nil {
+		if err := x.fail(op); err != nil {
+			return nil, err
+		}
+	}
+	x.applied = append(x.applied, op)
+
+	if op.Op == OperationRemove {
+		return nil, nil
+	}
+	post := new(Post)
+	if err := op.Unmarshal(post); err != nil {
+		return nil, err
+	}
+	return post, nil
+}
+
+func (x *fakeExecutor) Commit() error {
+	x.committed = true
+	return nil
+}
+
+func (x *fakeExecutor) Rollback() error {
+	x.rolledBack = true
+	return nil
+}
+
+func TestExecuteOperations(t *testing.T) {
+	payload := `{"atomic:operations": [
+		{"op": "add", "data": {"type": "posts", "lid": "a", "attributes": {"title": "One"}}},
+		{"op": "remove", "ref": {"type": "posts", "id": "2"}}
+	]}`
+	ops, _, err := UnmarshalOperations(strings.NewReader(payload), &sequenceGenerator{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	x := new(fakeExecutor)
+	results, err := ExecuteOperations(x, ops)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !x.committed || x.rolledBack || len(x.applied) != 2 {
+		t.Fatalf("Was expecting both operations to be applied and committed, got %+v", x)
+	}
+
+	out := bytes.NewBuffer(nil)
+	if err := MarshalOperationResults(out, results); err != nil {
+		t.Fatal(err)
+	}
+
+	var document map[string][]map[string]interface{}
+	if err := json.Unmarshal(out.Bytes(), &document); err != nil {
+		t.Fatal(err)
+	}
+	atomicResults := document["atomic:results"]
+	if len(atomicResults) != 2 {
+		t.Fatalf("Was expecting 2 results, got %s", out.String())
+	}
+	data := atomicResults[0]["data"].(map[string]interface{})
+	if data["type"] != "posts" || data["id"] != "100" {
+		t.Fatalf("Was expecting the added post as the first result, got %v", data)
+	}
+	if len(atomicResults[1]) != 0 {
+		t.Fatalf("Was expecting an empty second result, got %v", atomicResults[1])
+	}
+}
+
+func TestExecuteOperations_rollback(t *testing.T) {
+	payload := `{"atomic:operations": [
+		{"op": "add", "data": {"type": "posts", "attributes": {"title": "One"}}},
+		{"op": "add", "data": {"type": "posts", "attributes": {"title": "Two"}}},
+		{"op": "add", "data": {"type": "posts", "attributes": {"title": "Three"}}}
+	]}`
+	ops, _, err := UnmarshalOperations(strings.NewReader(payload), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	conflict := &ErrorObject{Title: "Conflict", Status: "409"}
+	x := &fakeExecutor{fail: func(op *Operation) error {
+		if op.Pointer() == "/atomic:operations/1" {
+			return conflict
+		}
+		return nil
+	}}
+
+	_, err = ExecuteOperations(x, ops)
+	var opErr *OperationError
+	if !errors.As(err, &opErr) || opErr.Index != 1 {
+		t.Fatalf("Was expecting an *OperationError for operation 1, got %v", err)
+	}
+	if !errors.Is(err, conflict) {
+		t.Fatalf("Was expecting the executor's error to be wrapped, got %v", err)
+	}
+	if x.committed || !x.rolledBack || len(x.applied) != 1 {
+		t.Fatalf("Was expecting a rollback after the first operation, got %+v", x)
+	}
+
+	objs := opErr.ErrorObjects()
+	if len(objs) != 1 || objs[0].Status != "409" || objs[0].Source == nil || objs[0].Source.Pointer != "/atomic:operations/1" {
+		t.Fatalf("Was expecting the conflict pointing at the operation, got %+v", objs)
+	}
+	if conflict.Source != nil {
+		t.Fatal("Was expecting the executor's error object to be left untouched")
+	}
+}
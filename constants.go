@@ -10,18 +10,42 @@ const (
 	annotationPolyRelation = "polyrelation"
 	annotationLinks        = "links"
 	annotationMeta         = "meta"
+	annotationRelLinks     = "rellinks"
+	annotationRelMeta      = "relmeta"
 	annotationOmitEmpty    = "omitempty"
 	annotationISO8601      = "iso8601"
 	annotationRFC3339      = "rfc3339"
+	annotationRFC3339Nano  = "rfc3339nano"
+	annotationDate         = "date"
+	annotationLayout       = "layout="
+	annotationUnixMilli    = "unixms"
+	annotationUnixNano     = "unixnano"
+	annotationKeepOffset   = "keepoffset"
+	annotationString       = "string"
+	annotationRequired     = "required"
+	annotationReadOnly     = "readonly"
+	annotationCreateOnly   = "createonly"
+	annotationWriteOnly    = "writeonly"
 	annotationSeparator    = ","
 
 	iso8601TimeFormat = "2006-01-02T15:04:05Z"
+	dateTimeFormat    = "2006-01-02"
 
 	// MediaType is the identifier for the JSON API media type
 	//
 	// see http://jsonapi.org/format/#document-structure
 	MediaType = "application/vnd.api+json"
 
+	// AtomicExtension is the URI of the Atomic Operations extension
+	//
+	// see https://jsonapi.org/ext/atomic/
+	AtomicExtension = "https://jsonapi.org/ext/atomic"
+
+	// AtomicMediaType is the JSON API media type with the Atomic Operations
+	// extension applied, used by the requests and responses that hold
+	// operations
+	AtomicMediaType = MediaType + `; ext="` + AtomicExtension + `"`
+
 	// Pagination Constants
 	//
 	// http://jsonapi.org/format/#fetching-pagination
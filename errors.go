@@ -2,8 +2,12 @@ package jsonapi
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"net/http"
+	"strconv"
+	"strings"
 )
 
 // MarshalErrors writes a JSON API response using the given `[]error`.
@@ -66,3 +70,124 @@ type ErrorSource struct {
 func (e *ErrorObject) Error() string {
 	return fmt.Sprintf("Error: %s %s\n", e.Title, e.Detail)
 }
+
+// UnmarshalError is returned by the Unmarshal functions when a member of the
+// request document could not be decoded into the target model.
+//
+// It records where the problem was found so that it can be reported back to
+// the client; ErrorObject converts it into a JSON API error object that can be
+// passed directly to `MarshalErrors`.
+type UnmarshalError struct {
+	// Pointer is a JSON Pointer (RFC6901) to the offending member of the request
+	// document, e.g. `/data/attributes/created_at`.
+	Pointer string
+
+	// Field is the name of the Go struct field that was being populated, if any.
+	Field string
+
+	// Status is the HTTP status code applicable to this problem: 409 Conflict
+	// when a resource type does not match the model's `primary` annotation,
+	// 422 Unprocessable Entity when a Validator rejects the decoded model, and
+	// 400 Bad Request otherwise.
+	Status int
+
+	// Err is the underlying error.
+	Err error
+}
+
+// Error implements the `Error` interface.
+func (e *UnmarshalError) Error() string {
+	if e.Pointer == "" {
+		return e.Err.Error()
+	}
+	return fmt.Sprintf("%s: %s", e.Pointer, e.Err.Error())
+}
+
+// Unwrap returns the underlying error, so that `errors.Is` and `errors.As`
+// can match sentinel errors such as ErrInvalidType.
+func (e *UnmarshalError) Unwrap() error {
+	return e.Err
+}
+
+// ErrorObject converts the error into a JSON API error object with its
+// source pointer set. If the underlying error is an *ErrorObject, such as one
+// returned by a Validator, a copy of it is used, with its title and status
+// filled in if missing.
+func (e *UnmarshalError) ErrorObject() *ErrorObject {
+	obj := &ErrorObject{
+		Title:  http.StatusText(e.Status),
+		Detail: e.Err.Error(),
+		Status: strconv.Itoa(e.Status),
+	}
+	var custom *ErrorObject
+	if errors.As(e.Err, &custom) {
+		title, status := obj.Title, obj.Status
+		copied := *custom
+		obj = &copied
+		if obj.Title == "" {
+			obj.Title = title
+		}
+		if obj.Status == "" {
+			obj.Status = status
+		}
+	}
+	if e.Pointer != "" {
+		obj.Source = &ErrorSource{Pointer: e.Pointer}
+	}
+	return obj
+}
+
+// UnmarshalErrors is returned by the Unmarshal functions in CollectAllErrors
+// mode when one or more members of the request document could not be decoded.
+type UnmarshalErrors []*UnmarshalError
+
+// Error implements the `Error` interface.
+func (e UnmarshalErrors) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// Unwrap returns the individual errors, so that `errors.Is` and `errors.As`
+// can match any of them.
+func (e UnmarshalErrors) Unwrap() []error {
+	errs := make([]error, len(e))
+	for i, err := range e {
+		errs[i] = err
+	}
+	return errs
+}
+
+// Is reports whether any of the errors matches target, for `errors.Is` on
+// Go versions before 1.20 that do not follow Unwrap() []error.
+func (e UnmarshalErrors) Is(target error) bool {
+	for _, err := range e {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// As finds the first of the errors that matches target, for `errors.As` on
+// Go versions before 1.20 that do not follow Unwrap() []error.
+func (e UnmarshalErrors) As(target interface{}) bool {
+	for _, err := range e {
+		if errors.As(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// ErrorObjects converts the errors into JSON API error objects, ready to be
+// passed to `MarshalErrors`.
+func (e UnmarshalErrors) ErrorObjects() []*ErrorObject {
+	objs := make([]*ErrorObject, len(e))
+	for i, err := range e {
+		objs[i] = err.ErrorObject()
+	}
+	return objs
+}
@@ -3,8 +3,10 @@ package jsonapi
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"net/http"
 	"reflect"
 	"testing"
 )
@@ -57,3 +59,60 @@ func TestMarshalErrorsWritesTheExpectedPayload(t *testing.T) {
 		})
 	}
 }
+
+func TestUnmarshalErrorConvertsToErrorObject(t *testing.T) {
+	err := &UnmarshalError{
+		Pointer: "/data/attributes/created_at",
+		Field:   "CreatedAt",
+		Status:  http.StatusBadRequest,
+		Err:     ErrInvalidTime,
+	}
+
+	if !errors.Is(err, ErrInvalidTime) {
+		t.Fatalf("expected %v to wrap %v", err, ErrInvalidTime)
+	}
+
+	buffer := bytes.NewBuffer(nil)
+	if e := MarshalErrors(buffer, []*ErrorObject{err.ErrorObject()}); e != nil {
+		t.Fatal(e)
+	}
+
+	output := map[string]interface{}{}
+	if e := json.Unmarshal(buffer.Bytes(), &output); e != nil {
+		t.Fatalf("failed to unmarshal: %v", e)
+	}
+
+	expected := map[string]interface{}{"errors": []interface{}{
+		map[string]interface{}{
+			"title":  "Bad Request",
+			"detail": ErrInvalidTime.Error(),
+			"status": "400",
+			"source": map[string]interface{}{"pointer": "/data/attributes/created_at"},
+		},
+	}}
+	if !reflect.DeepEqual(output, expected) {
+		t.Fatalf("Expected: \n%#v \nto equal: \n%#v", output, expected)
+	}
+}
+
+func TestUnmarshalErrorsMatchesEachError(t *testing.T) {
+	errs := UnmarshalErrors{
+		{Pointer: "/data/attributes/created_at", Status: http.StatusBadRequest, Err: ErrInvalidTime},
+		{Pointer: "/data/attributes/title", Status: http.StatusUnprocessableEntity, Err: ErrMissingMember},
+	}
+
+	// Is and As are called directly, as errors.Is and errors.As only fall
+	// back to them before Go 1.20
+	if !errs.Is(ErrMissingMember) || errs.Is(ErrInvalidType) {
+		t.Fatal("Was expecting Is to match the wrapped errors only")
+	}
+	var unmarshalErr *UnmarshalError
+	if !errs.As(&unmarshalErr) || unmarshalErr != errs[0] {
+		t.Fatalf("Was expecting As to find the first error, got %v", unmarshalErr)
+	}
+
+	wrapped := fmt.Errorf("operation 0: %w", errs)
+	if !errors.Is(wrapped, ErrInvalidTime) || !errors.As(wrapped, &unmarshalErr) {
+		t.Fatalf("Was expecting %v to match through the wrapping", wrapped)
+	}
+}
@@ -0,0 +1,181 @@
+package jsonapi
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// modelFieldsCache maps struct types to their modelFields, which are
+// computed once per type.
+var modelFieldsCache sync.Map // map[reflect.Type][]reflect.StructField
+
+// modelFields returns the fields of the struct type t that carry a jsonapi
+// tag, in declaration order. Fields of anonymous embedded structs, or
+// pointers to structs, that have no jsonapi tag of their own are promoted
+// into t, with their Index holding the path to them from t.
+//
+// Promoted fields follow the rules of encoding/json: a field declared on t
+// always takes precedence over a promoted one for the same member, a field
+// promoted from a shallower depth over one promoted from a deeper one, and
+// fields promoted from the same depth for the same member are all dropped,
+// unless a single one of them is a `polyrelation`, which is kept as it is
+// preferred over a `relation` of the same name. An embedded struct tagged
+// `jsonapi:"-"` is not promoted.
+func modelFields(t reflect.Type) []reflect.StructField {
+	if fields, ok := modelFieldsCache.Load(t); ok {
+		return fields.([]reflect.StructField)
+	}
+	fields, _ := modelFieldsCache.LoadOrStore(t, typeModelFields(t))
+	return fields.([]reflect.StructField)
+}
+
+// embeddedStruct is a struct type embedded at index within a model.
+type embeddedStruct struct {
+	typ   reflect.Type
+	index []int
+}
+
+func typeModelFields(t reflect.Type) []reflect.StructField {
+	var fields []reflect.StructField
+
+	// Members that are taken by fields from a shallower depth
+	taken := map[string]bool{}
+	visited := map[reflect.Type]bool{}
+
+	current := []embeddedStruct{{typ: t}}
+	for depth := 0; len(current) > 0; depth++ {
+		var next []embeddedStruct
+		var level []reflect.StructField
+		count, polyCount := map[string]int{}, map[string]int{}
+
+		for _, embedded := range current {
+			if visited[embedded.typ] {
+				continue
+			}
+			visited[embedded.typ] = true
+
+			for i := 0; i < embedded.typ.NumField(); i++ {
+				field := embedded.typ.Field(i)
+				field.Index = append(append([]int(nil), embedded.index...), i)
+
+				tag := field.Tag.Get(annotationJSONAPI)
+				if tag == "-" {
+					continue
+				}
+				if tag == "" {
+					if field.Anonymous {
+						if ft := indirectType(field.Type); ft.Kind() == reflect.Struct {
+							next = append(next, embeddedStruct{typ: ft, index: field.Index})
+						}
+					}
+					continue
+				}
+
+				level = append(level, field)
+				count[memberKey(tag)]++
+				if isPolyRelation(tag) {
+					polyCount[memberKey(tag)]++
+				}
+			}
+		}
+
+		for _, field := range level {
+			tag := field.Tag.Get(annotationJSONAPI)
+			key := memberKey(tag)
+			// Fields declared on the model itself are kept as they are
+			if depth > 0 && (taken[key] || count[key] > 1 && !(isPolyRelation(tag) && polyCount[key] == 1)) {
+				continue
+			}
+			fields = append(fields, field)
+		}
+		for key := range count {
+			taken[key] = true
+		}
+
+		current = next
+	}
+
+	sort.SliceStable(fields, func(i, j int) bool {
+		a, b := fields[i].Index, fields[j].Index
+		for k := 0; k < len(a) && k < len(b); k++ {
+			if a[k] != b[k] {
+				return a[k] < b[k]
+			}
+		}
+		return len(a) < len(b)
+	})
+	return fields
+}
+
+// memberKey returns the name of the part of a resource object that a field
+// with the given jsonapi tag is encoded to, for fields of the same model to
+// be compared by. Attributes and relationships share the namespace of a
+// resource's fields.
+func memberKey(tag string) string {
+	args := strings.Split(tag, annotationSeparator)
+
+	switch args[0] {
+	case annotationPrimary, annotationClientID, annotationLinks, annotationMeta:
+		return args[0]
+	case annotationAttribute, annotationRelation, annotationPolyRelation:
+		if len(args) > 1 {
+			return "fields," + args[1]
+		}
+	}
+	return tag
+}
+
+// isPolyRelation reports whether tag is the jsonapi tag of a `polyrelation`.
+func isPolyRelation(tag string) bool {
+	return strings.HasPrefix(tag, annotationPolyRelation+annotationSeparator)
+}
+
+// fieldByIndex returns the field of the struct v at index, as
+// reflect.Value.FieldByIndex does, except that nil embedded struct pointers
+// on the way are allocated when alloc is set. Otherwise, or when the pointer
+// is to an unexported type that cannot be set, false is returned.
+func fieldByIndex(v reflect.Value, index []int, alloc bool) (reflect.Value, bool) {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				if !alloc || !v.CanSet() {
+					return reflect.Value{}, false
+				}
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v, true
+}
+
+// nodeHasMember reports whether data holds the member decoded into a field
+// with the jsonapi tag args.
+func nodeHasMember(data *Node, args []string) bool {
+	switch args[0] {
+	case annotationPrimary:
+		return true
+	case annotationClientID:
+		return data.ClientID != ""
+	case annotationAttribute:
+		_, ok := data.Attributes[args[1]]
+		return ok
+	case annotationRelation, annotationPolyRelation, annotationRelLinks, annotationRelMeta:
+		return data.Relationships[args[1]] != nil
+	case annotationLinks:
+		return data.Links != nil
+	case annotationMeta:
+		return data.Meta != nil
+	}
+	return false
+}
+
+func indirectType(t reflect.Type) reflect.Type {
+	if t.Kind() == reflect.Ptr {
+		return t.Elem()
+	}
+	return t
+}
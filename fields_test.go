@@ -0,0 +1,137 @@
+package jsonapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestModelFields_precedence(t *testing.T) {
+	var names []string
+	for _, field := range modelFields(reflect.TypeOf(Widget{})) {
+		names = append(names, field.Name)
+	}
+
+	// BaseModel.Name is shadowed by Widget.Name, the two labels are
+	// promoted from the same depth and cancel out, and Hidden is ignored
+	expected := []string{"ID", "CreatedAt", "UpdatedAt", "Name", "Owner"}
+	if !reflect.DeepEqual(names, expected) {
+		t.Fatalf("Was expecting fields %v, got %v", expected, names)
+	}
+}
+
+func TestModelFields_polyrelationPrecedence(t *testing.T) {
+	fields := modelFields(reflect.TypeOf(Feature{}))
+
+	// The relation and polyrelation promoted from the same depth collide,
+	// and the polyrelation is kept
+	if len(fields) != 2 || fields[1].Type != reflect.TypeOf(new(OneOfMedia)) {
+		t.Fatalf("Was expecting the ID and the polyrelation, got %+v", fields)
+	}
+
+	payload := `{"data": {"type": "features", "id": "f1", "relationships": {"hero": {"data": {"type": "images", "id": "i1"}}}}}`
+	feature := new(Feature)
+	if err := UnmarshalPayload(strings.NewReader(payload), feature); err != nil {
+		t.Fatal(err)
+	}
+	if feature.HeroPolyRelation.Hero == nil || feature.HeroPolyRelation.Hero.Image == nil || feature.HeroPolyRelation.Hero.Image.ID != "i1" {
+		t.Fatalf("Was expecting the hero image to be decoded, got %+v", feature.HeroPolyRelation.Hero)
+	}
+}
+
+func TestUnmarshalPayload_embeddedStructs(t *testing.T) {
+	payload := `{
+		"data": {
+			"type": "widgets",
+			"id": "w1",
+			"attributes": {
+				"name": "Sprocket",
+				"label": "ignored",
+				"created_at": "2016-08-17T08:27:12Z"
+			},
+			"relationships": {
+				"owner": {"data": {"type": "users", "id": "2"}}
+			}
+		}
+	}`
+
+	widget := new(Widget)
+	if err := UnmarshalPayload(strings.NewReader(payload), widget); err != nil {
+		t.Fatal(err)
+	}
+
+	if widget.ID != "w1" {
+		t.Fatalf("Was expecting the promoted primary to be set, got %q", widget.ID)
+	}
+	if widget.Name != "Sprocket" || widget.BaseModel.Name != "" {
+		t.Fatalf("Was expecting only the outer name to be set, got %q and %q", widget.Name, widget.BaseModel.Name)
+	}
+	if widget.Labelled.Label != "" || widget.Tagged.Label != "" {
+		t.Fatal("Was expecting the ambiguous label to be ignored")
+	}
+	if widget.Timestamps == nil || !widget.CreatedAt.Equal(time.Date(2016, 8, 17, 8, 27, 12, 0, time.UTC)) {
+		t.Fatalf("Was expecting the embedded pointer to be allocated for created_at, got %+v", widget.Timestamps)
+	}
+	if widget.Owner == nil || widget.Owner.ID != 2 {
+		t.Fatalf("Was expecting the owner, got %+v", widget.Owner)
+	}
+}
+
+func TestUnmarshalPayload_embeddedPointerLeftNil(t *testing.T) {
+	payload := `{"data": {"type": "widgets", "id": "w1", "attributes": {"name": "Sprocket"}}}`
+
+	widget := new(Widget)
+	if err := UnmarshalPayload(strings.NewReader(payload), widget); err != nil {
+		t.Fatal(err)
+	}
+	if widget.Timestamps != nil {
+		t.Fatalf("Was expecting the embedded pointer to stay nil, got %+v", widget.Timestamps)
+	}
+}
+
+func TestMarshalPayload_embeddedStructs(t *testing.T) {
+	for _, tc := range []struct {
+		desc       string
+		timestamps *Timestamps
+		expected   map[string]interface{}
+	}{
+		{
+			desc:       "nilPointer",
+			timestamps: nil,
+			expected:   map[string]interface{}{"name": "Sprocket"},
+		},
+		{
+			desc:       "setPointer",
+			timestamps: &Timestamps{CreatedAt: time.Date(2016, 8, 17, 8, 27, 12, 0, time.UTC)},
+			expected:   map[string]interface{}{"name": "Sprocket", "created_at": "2016-08-17T08:27:12Z"},
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			widget := &Widget{
+				BaseModel: BaseModel{ID: "w1", Name: "shadowed", Timestamps: tc.timestamps},
+				Labelled:  Labelled{Label: "a"},
+				Tagged:    Tagged{Label: "b"},
+				Name:      "Sprocket",
+			}
+
+			out := bytes.NewBuffer(nil)
+			if err := MarshalPayload(out, widget); err != nil {
+				t.Fatal(err)
+			}
+
+			var payload OnePayload
+			if err := json.Unmarshal(out.Bytes(), &payload); err != nil {
+				t.Fatal(err)
+			}
+			if payload.Data.Type != "widgets" || payload.Data.ID != "w1" {
+				t.Fatalf("Was expecting the promoted primary, got %s %s", payload.Data.Type, payload.Data.ID)
+			}
+			if !reflect.DeepEqual(payload.Data.Attributes, tc.expected) {
+				t.Fatalf("Was expecting attributes %v, got %v", tc.expected, payload.Data.Attributes)
+			}
+		})
+	}
+}
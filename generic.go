@@ -0,0 +1,83 @@
+package jsonapi
+
+import (
+	"errors"
+	"io"
+	"reflect"
+)
+
+// UnmarshalOne decodes a request document holding a single resource into a
+// new T, which must be a struct with jsonapi tags, and returns it.
+//
+//	blog, err := jsonapi.UnmarshalOne[Blog](r.Body)
+//
+// On error the model is only returned in CollectAllErrors mode, alongside the
+// UnmarshalErrors, so that callers can inspect whatever could be decoded.
+func UnmarshalOne[T any](in io.Reader, opts ...UnmarshalOption) (*T, error) {
+	model := new(T)
+	if err := UnmarshalPayload(in, model, opts...); err != nil {
+		return partial(model, err)
+	}
+	return model, nil
+}
+
+// UnmarshalMany decodes a request document holding many resources into new
+// instances of T, which must be a struct with jsonapi tags, and returns them
+// in the order they appear in "data".
+//
+//	blogs, err := jsonapi.UnmarshalMany[Blog](r.Body)
+//
+// On error the models are only returned in CollectAllErrors mode, alongside
+// the UnmarshalErrors, so that callers can inspect whatever could be decoded.
+func UnmarshalMany[T any](in io.Reader, opts ...UnmarshalOption) ([]*T, error) {
+	elems, err := UnmarshalManyPayload(in, reflect.TypeOf(new(T)), opts...)
+
+	models := make([]*T, len(elems))
+	for i, elem := range elems {
+		models[i] = elem.(*T)
+	}
+
+	if err != nil {
+		return partial(models, err)
+	}
+	return models, nil
+}
+
+// UnmarshalEach is the typed form of UnmarshalManyPayloadEach. It passes
+// each resource of a many payload to fn as a new instance of T as soon as it
+// has been decoded.
+//
+//	err := jsonapi.UnmarshalEach(r.Body, func(blog *Blog) error {
+//		return db.Save(blog)
+//	})
+func UnmarshalEach[T any](in io.Reader, fn func(model *T) error, opts ...UnmarshalOption) error {
+	return UnmarshalManyPayloadEach(in, reflect.TypeOf(new(T)), func(model interface{}) error {
+		return fn(model.(*T))
+	}, opts...)
+}
+
+// MarshalTo writes a document holding the single resource model to w, as
+// MarshalPayload does.
+func MarshalTo[T any](w io.Writer, model *T, opts ...MarshalOption) error {
+	return MarshalPayload(w, model, opts...)
+}
+
+// MarshalManyTo writes a document holding the resources models to w, as
+// MarshalPayload does. A nil or empty slice is written as an empty "data"
+// array.
+func MarshalManyTo[T any](w io.Writer, models []*T, opts ...MarshalOption) error {
+	return MarshalPayload(w, models, opts...)
+}
+
+// partial returns v alongside err if err reports the members of the request
+// document that could not be decoded in CollectAllErrors mode, and the zero
+// value of V otherwise.
+func partial[V any](v V, err error) (V, error) {
+	var errs UnmarshalErrors
+	if errors.As(err, &errs) {
+		return v, err
+	}
+
+	var zero V
+	return zero, err
+}
@@ -0,0 +1,112 @@
+package jsonapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestUnmarshalOne(t *testing.T) {
+	in := strings.NewReader(`{"data": {"type": "posts", "id": "1", "attributes": {"title": "Typed"}}}`)
+
+	post, err := UnmarshalOne[Post](in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if post.ID != 1 || post.Title != "Typed" {
+		t.Fatalf("Unexpected post %+v", post)
+	}
+}
+
+func TestUnmarshalOne_error(t *testing.T) {
+	payload := `{"data": {"type": "posts", "id": "1", "attributes": {"title": 1}}}`
+
+	post, err := UnmarshalOne[Post](strings.NewReader(payload))
+	if err == nil || post != nil {
+		t.Fatalf("Was expecting only an error, got %+v, %v", post, err)
+	}
+
+	// Whatever could be decoded is returned when collecting errors
+	post, err = UnmarshalOne[Post](strings.NewReader(payload), CollectAllErrors())
+	var errs UnmarshalErrors
+	if !errors.As(err, &errs) {
+		t.Fatalf("Was expecting UnmarshalErrors, got %T: %v", err, err)
+	}
+	if post == nil || post.ID != 1 {
+		t.Fatalf("Was expecting the partially decoded post, got %+v", post)
+	}
+}
+
+func TestUnmarshalMany(t *testing.T) {
+	posts, err := UnmarshalMany[Post](strings.NewReader(`{` + streamData + `,` + streamIncluded + `}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(posts) != 2 || posts[0].ID != 1 || posts[1].ID != 2 {
+		t.Fatalf("Unexpected posts %+v", posts)
+	}
+	if posts[0].LatestComment == nil || posts[0].LatestComment.Body != "Hello" {
+		t.Fatalf("Was expecting the latest comment to be resolved, got %+v", posts[0].LatestComment)
+	}
+}
+
+func TestUnmarshalEach(t *testing.T) {
+	var titles []string
+	err := UnmarshalEach(strings.NewReader(`{`+streamData+`}`), func(post *Post) error {
+		titles = append(titles, post.Title)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if strings.Join(titles, ",") != "First,Second" {
+		t.Fatalf("Unexpected titles %v", titles)
+	}
+}
+
+func TestMarshalTo(t *testing.T) {
+	out := bytes.NewBuffer(nil)
+	if err := MarshalTo(out, &Post{ID: 1, Title: "Typed"}); err != nil {
+		t.Fatal(err)
+	}
+
+	post, err := UnmarshalOne[Post](out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if post.ID != 1 || post.Title != "Typed" {
+		t.Fatalf("Was expecting the post to round trip, got %+v", post)
+	}
+}
+
+func TestMarshalManyTo(t *testing.T) {
+	out := bytes.NewBuffer(nil)
+	if err := MarshalManyTo(out, []*Post{{ID: 1}, {ID: 2}}); err != nil {
+		t.Fatal(err)
+	}
+
+	posts, err := UnmarshalMany[Post](out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(posts) != 2 || posts[0].ID != 1 || posts[1].ID != 2 {
+		t.Fatalf("Was expecting the posts to round trip, got %+v", posts)
+	}
+
+	out.Reset()
+	if err := MarshalManyTo[Post](out, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	var payload map[string]interface{}
+	if err := json.Unmarshal(out.Bytes(), &payload); err != nil {
+		t.Fatal(err)
+	}
+	if data, ok := payload["data"].([]interface{}); !ok || len(data) != 0 {
+		t.Fatalf("Was expecting an empty data array, got %v", payload["data"])
+	}
+}
@@ -1,6 +1,8 @@
 package jsonapi
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
 	"time"
 )
@@ -35,6 +37,18 @@ type TimestampModel struct {
 	RFC3339P *time.Time `jsonapi:"attr,rfc3339p,rfc3339"`
 }
 
+type TimeFormatsModel struct {
+	ID          int                     `jsonapi:"primary,time-formats"`
+	UnixMilli   time.Time               `jsonapi:"attr,unixms,unixms"`
+	UnixNano    *time.Time              `jsonapi:"attr,unixnano,unixnano"`
+	RFC3339Nano time.Time               `jsonapi:"attr,rfc3339nano,rfc3339nano"`
+	Date        time.Time               `jsonapi:"attr,date,date"`
+	Layout      time.Time               `jsonapi:"attr,layout,layout=02 Jan 06 15:04 -0700"`
+	KeepOffset  time.Time               `jsonapi:"attr,keepoffset,rfc3339,keepoffset"`
+	Nullable    NullableAttr[time.Time] `jsonapi:"attr,nullable,date,omitempty"`
+	Slice       []time.Time             `jsonapi:"attr,slice,unixms"`
+}
+
 type WithNullableAttrs struct {
 	ID              int                            `jsonapi:"primary,with-nullables"`
 	Name            string                         `jsonapi:"attr,name"`
@@ -45,6 +59,20 @@ type WithNullableAttrs struct {
 	NullableComment NullableRelationship[*Comment] `jsonapi:"relation,nullable_comment,omitempty"`
 }
 
+type WithNullableRelationships struct {
+	ID       int                                 `jsonapi:"primary,with-nullable-relationships"`
+	Comments NullableRelationship[[]*Comment]    `jsonapi:"relation,comments,omitempty"`
+	Hero     NullableRelationship[*OneOfMedia]   `jsonapi:"polyrelation,hero,omitempty"`
+	Media    NullableRelationship[[]*OneOfMedia] `jsonapi:"polyrelation,media,omitempty"`
+}
+
+type WithOptionals struct {
+	ID    int                 `jsonapi:"primary,with-optionals"`
+	Name  Optional[string]    `jsonapi:"attr,name"`
+	Count Optional[int]       `jsonapi:"attr,count"`
+	Born  Optional[time.Time] `jsonapi:"attr,born,iso8601"`
+}
+
 type Car struct {
 	ID    *string `jsonapi:"primary,cars"`
 	Make  *string `jsonapi:"attr,make,omitempty"`
@@ -74,6 +102,71 @@ type Comment struct {
 	Links Links `jsonapi:"links,omitempty"`
 }
 
+type User struct {
+	ID         int     `jsonapi:"primary,users"`
+	Name       string  `jsonapi:"attr,name"`
+	BestFriend *User   `jsonapi:"relation,best_friend"`
+	Friends    []*User `jsonapi:"relation,friends"`
+}
+
+type Article struct {
+	ID            int        `jsonapi:"primary,articles"`
+	Comments      []*Comment `jsonapi:"relation,comments"`
+	CommentsMeta  Meta       `jsonapi:"relmeta,comments"`
+	CommentsLinks *Links     `jsonapi:"rellinks,comments"`
+	Author        *User      `jsonapi:"relation,author"`
+	AuthorLinks   Links      `jsonapi:"rellinks,author"`
+	AuthorMeta    *Meta      `jsonapi:"relmeta,author"`
+}
+
+type Person struct {
+	ID      string  `jsonapi:"primary,people"`
+	Name    string  `jsonapi:"attr,name"`
+	Partner *Person `jsonapi:"relation,partner,omitempty"`
+}
+
+type Timestamps struct {
+	CreatedAt time.Time  `jsonapi:"attr,created_at,iso8601"`
+	UpdatedAt *time.Time `jsonapi:"attr,updated_at,iso8601,omitempty"`
+}
+
+type BaseModel struct {
+	ID   string `jsonapi:"primary,widgets"`
+	Name string `jsonapi:"attr,name"`
+	*Timestamps
+}
+
+type Labelled struct {
+	Label string `jsonapi:"attr,label"`
+}
+
+type Tagged struct {
+	Label string `jsonapi:"attr,label"`
+}
+
+type Widget struct {
+	BaseModel
+	Labelled
+	Tagged
+	Name   string  `jsonapi:"attr,name"`
+	Owner  *User   `jsonapi:"relation,owner,omitempty"`
+	Hidden Comment `jsonapi:"-"`
+}
+
+type HeroRelation struct {
+	Hero *Image `jsonapi:"relation,hero"`
+}
+
+type HeroPolyRelation struct {
+	Hero *OneOfMedia `jsonapi:"polyrelation,hero"`
+}
+
+type Feature struct {
+	ID string `jsonapi:"primary,features"`
+	HeroRelation
+	HeroPolyRelation
+}
+
 type Book struct {
 	ID          uint64  `jsonapi:"primary,books"`
 	Author      string  `jsonapi:"attr,author"`
@@ -234,6 +327,102 @@ type CustomAttributeTypes struct {
 	String CustomStringType `jsonapi:"attr,string"`
 }
 
+// Money is encoded as text, e.g. "12.50 EUR".
+type Money struct {
+	Cents    int64
+	Currency string
+}
+
+func (m Money) MarshalText() ([]byte, error) {
+	return []byte(fmt.Sprintf("%d.%02d %s", m.Cents/100, m.Cents%100, m.Currency)), nil
+}
+
+func (m *Money) UnmarshalText(text []byte) error {
+	var units, cents int64
+	if _, err := fmt.Sscanf(string(text), "%d.%02d %s", &units, &cents, &m.Currency); err != nil {
+		return fmt.Errorf("invalid money %q: %w", text, err)
+	}
+	m.Cents = units*100 + cents
+	return nil
+}
+
+// Point is encoded as a JSON array of its coordinates.
+type Point struct {
+	X, Y int
+}
+
+func (p Point) MarshalJSON() ([]byte, error) {
+	return []byte(fmt.Sprintf("[%d,%d]", p.X, p.Y)), nil
+}
+
+func (p *Point) UnmarshalJSON(data []byte) error {
+	var xy [2]int
+	if err := json.Unmarshal(data, &xy); err != nil {
+		return err
+	}
+	p.X, p.Y = xy[0], xy[1]
+	return nil
+}
+
+type Invoice struct {
+	ID       string              `jsonapi:"primary,invoices"`
+	Total    Money               `jsonapi:"attr,total"`
+	Discount *Money              `jsonapi:"attr,discount"`
+	Refund   *Money              `jsonapi:"attr,refund,omitempty"`
+	Lines    []Money             `jsonapi:"attr,lines"`
+	Tip      NullableAttr[Money] `jsonapi:"attr,tip,omitempty"`
+	Origin   Point               `jsonapi:"attr,origin"`
+	Route    []*Point            `jsonapi:"attr,route"`
+	Dest     NullableAttr[Point] `jsonapi:"attr,dest,omitempty"`
+}
+
+type Address struct {
+	Street string `jsonapi:"attr,street"`
+	City   string `jsonapi:"attr,city"`
+}
+
+type Settings map[string]interface{}
+
+type Profile struct {
+	ID        string                        `jsonapi:"primary,profiles"`
+	Settings  Settings                      `jsonapi:"attr,settings"`
+	Limits    map[string]int                `jsonapi:"attr,limits"`
+	Flags     map[int]bool                  `jsonapi:"attr,flags"`
+	Addresses map[string]Address            `jsonapi:"attr,addresses"`
+	Contacts  map[string]*Address           `jsonapi:"attr,contacts"`
+	Weights   map[string]map[string]float64 `jsonapi:"attr,weights"`
+	History   []map[string]string           `jsonapi:"attr,history"`
+	Extra     map[string]string             `jsonapi:"attr,extra,omitempty"`
+}
+
+type Measurements struct {
+	ID     string       `jsonapi:"primary,measurements"`
+	Counts []int        `jsonapi:"attr,counts"`
+	Small  []int8       `jsonapi:"attr,small"`
+	Ratios []float64    `jsonapi:"attr,ratios"`
+	Flags  []bool       `jsonapi:"attr,flags"`
+	Labels []*string    `jsonapi:"attr,labels"`
+	Taken  []time.Time  `jsonapi:"attr,taken,iso8601"`
+	Stamps []*time.Time `jsonapi:"attr,stamps"`
+	Grid   [][]string   `jsonapi:"attr,grid"`
+	Pair   [2]int       `jsonapi:"attr,pair"`
+	Blob   []byte       `jsonapi:"attr,blob"`
+}
+
+type BigNumbers struct {
+	ID          uint64      `jsonapi:"primary,big-numbers"`
+	Int8        int8        `jsonapi:"attr,int8,omitempty"`
+	Uint8       uint8       `jsonapi:"attr,uint8,omitempty"`
+	Int         int         `jsonapi:"attr,int,omitempty"`
+	Int64       int64       `jsonapi:"attr,int64,omitempty"`
+	Uint64      uint64      `jsonapi:"attr,uint64,omitempty"`
+	Float32     float32     `jsonapi:"attr,float32,omitempty"`
+	Quoted      int64       `jsonapi:"attr,quoted,string,omitempty"`
+	QuotedPtr   *uint64     `jsonapi:"attr,quoted-ptr,string,omitempty"`
+	QuotedSlice []int64     `jsonapi:"attr,quoted-slice,string,omitempty"`
+	Any         interface{} `jsonapi:"attr,any,omitempty"`
+}
+
 type Image struct {
 	ID  string `jsonapi:"primary,images"`
 	Src string `jsonapi:"attr,src"`
@@ -256,3 +445,53 @@ type BlogPostWithPoly struct {
 	Hero  *OneOfMedia   `jsonapi:"polyrelation,hero-media,omitempty"`
 	Media []*OneOfMedia `jsonapi:"polyrelation,media,omitempty"`
 }
+
+// Media is implemented by the models that can be decoded into a Gallery once
+// registered with RegisterType.
+type Media interface {
+	MediaID() string
+}
+
+func (i *Image) MediaID() string { return i.ID }
+func (v *Video) MediaID() string { return v.ID }
+
+type Gallery struct {
+	ID    string  `jsonapi:"primary,galleries"`
+	Cover Media   `jsonapi:"relation,cover,omitempty"`
+	Items []Media `jsonapi:"relation,items"`
+}
+
+type Draft struct {
+	ID    string `jsonapi:"primary,drafts"`
+	Title string `jsonapi:"attr,title"`
+	Words int    `jsonapi:"attr,words"`
+}
+
+func (d *Draft) ValidateJSONAPI() error {
+	if d.ID == "rejected" {
+		return errors.New("drafts cannot be rejected")
+	}
+
+	var errs FieldErrors
+	if d.Title == "" {
+		errs = append(errs, &FieldError{Field: "Title", Err: errors.New("must not be blank")})
+	}
+	if d.Words < 0 {
+		errs = append(errs, &FieldError{Field: "Words", Err: &ErrorObject{Code: "negative", Detail: "must not be negative"}})
+	}
+	return errs
+}
+
+type Account struct {
+	ID       string `jsonapi:"primary,accounts"`
+	Email    string `jsonapi:"attr,email,required"`
+	Username string `jsonapi:"attr,username,createonly,omitempty"`
+	Password string `jsonapi:"attr,password,writeonly,omitempty"`
+	Balance  int    `jsonapi:"attr,balance,readonly"`
+	Owner    *User  `jsonapi:"relation,owner,required,omitempty"`
+}
+
+type CommaTimeLayout struct {
+	ID       int       `jsonapi:"primary,comma-time-layouts"`
+	Modified time.Time `jsonapi:"attr,modified,layout=Mon, 02 Jan 2006 15:04:05 MST,omitempty"`
+}
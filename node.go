@@ -10,10 +10,11 @@ type Payloader interface {
 // OnePayload is used to represent a generic JSON API payload where a single
 // resource (Node) was included as an {} in the "data" key
 type OnePayload struct {
-	Data     *Node   `json:"data"`
-	Included []*Node `json:"included,omitempty"`
-	Links    *Links  `json:"links,omitempty"`
-	Meta     *Meta   `json:"meta,omitempty"`
+	Data     *Node          `json:"data"`
+	Included []*Node        `json:"included,omitempty"`
+	Links    *Links         `json:"links,omitempty"`
+	Meta     *Meta          `json:"meta,omitempty"`
+	JSONAPI  *JSONAPIObject `json:"jsonapi,omitempty"`
 }
 
 func (p *OnePayload) clearIncluded() {
@@ -23,16 +24,40 @@ func (p *OnePayload) clearIncluded() {
 // ManyPayload is used to represent a generic JSON API payload where many
 // resources (Nodes) were included in an [] in the "data" key
 type ManyPayload struct {
-	Data     []*Node `json:"data"`
-	Included []*Node `json:"included,omitempty"`
-	Links    *Links  `json:"links,omitempty"`
-	Meta     *Meta   `json:"meta,omitempty"`
+	Data     []*Node        `json:"data"`
+	Included []*Node        `json:"included,omitempty"`
+	Links    *Links         `json:"links,omitempty"`
+	Meta     *Meta          `json:"meta,omitempty"`
+	JSONAPI  *JSONAPIObject `json:"jsonapi,omitempty"`
 }
 
 func (p *ManyPayload) clearIncluded() {
 	p.Included = []*Node{}
 }
 
+// JSONAPIObject is used to represent the top-level `jsonapi` object, which
+// describes the server's implementation of the spec.
+// http://jsonapi.org/format/#document-jsonapi-object
+type JSONAPIObject struct {
+	Version string   `json:"version,omitempty"`
+	Ext     []string `json:"ext,omitempty"`
+	Profile []string `json:"profile,omitempty"`
+	Meta    *Meta    `json:"meta,omitempty"`
+}
+
+// Document holds the top-level members of a decoded document other than its
+// primary data, as returned by UnmarshalDocument and UnmarshalManyDocument.
+// Link objects in Links are decoded to Link values, and numbers in Links and
+// Meta to float64, as they are for the `links` and `meta` fields of a model.
+type Document struct {
+	Links   *Links
+	Meta    *Meta
+	JSONAPI *JSONAPIObject
+	// Included holds the resources of the "included" array as they were
+	// read, with numbers in their attributes and meta left as json.Number.
+	Included []*Node
+}
+
 // Node is used to represent a generic JSON API Resource
 type Node struct {
 	Type          string                 `json:"type"`
@@ -1,7 +1,14 @@
 package jsonapi
 
 import (
+	"bytes"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
 )
 
 // NullableAttr is a generic type, which implements a field that can be one of three states:
@@ -45,13 +52,19 @@ type NullableAttr[T any] map[bool]T
 //
 // If the relationship is expected to be optional, add the `omitempty` JSON tags. Do NOT use `*NullableRelationship`!
 //
-// Slice types are not currently supported for NullableRelationships as the nullable nature can be expressed via empty array
-// `polyrelation` JSON tags are NOT currently supported.
+// To-many relationships distinguish a relationship that was not sent from one
+// set to an empty array, which is a value. As to-many linkage cannot be
+// `null`, an explicit null is marshaled as an empty array, while a `null`
+// sent for one is unmarshaled as an explicit null.
 //
-// NullableRelationships must have an inner type of pointer:
+// `polyrelation` JSON tags are supported with choice type struct inner types.
+//
+// NullableRelationships must have an inner type of pointer, or slice of
+// pointers:
 //
 // - NullableRelationship[*Comment] - valid
-// - NullableRelationship[[]*Comment] - invalid
+// - NullableRelationship[[]*Comment] - valid
+// - NullableRelationship[*OneOfMedia] - valid, with `polyrelation`
 // - NullableRelationship[Comment] - invalid
 type NullableRelationship[T any] map[bool]T
 
@@ -179,3 +192,318 @@ func (t NullableRelationship[T]) IsSpecified() bool {
 func (t *NullableRelationship[T]) SetUnspecified() {
 	*t = map[bool]T{}
 }
+
+// Optional is a generic type, which implements an attribute that can be one
+// of the same three states as NullableAttr:
+//
+// - field is not set in the request
+// - field is explicitly set to `null` in the request
+// - field is explicitly set to a valid value in the request
+//
+// Unlike NullableAttr, Optional is a struct rather than a map, so setting it
+// does not allocate and two Optionals can be compared with ==. Its zero value
+// is unspecified.
+//
+//	type Post struct {
+//		ID    int              `jsonapi:"primary,posts"`
+//		Title Optional[string] `jsonapi:"attr,title"`
+//	}
+//
+// Optional is supported wherever NullableAttr is. Do NOT use `*Optional`!
+type Optional[T any] struct {
+	value T
+	state optionalState
+}
+
+// optionalState is the state of an Optional, the zero value being
+// unspecified.
+type optionalState uint8
+
+const (
+	optionalUnspecified optionalState = iota
+	optionalNull
+	optionalValue
+)
+
+// NewOptional is a convenience helper to allow constructing an Optional with a
+// given value, for instance to construct a field inside a struct without
+// introducing an intermediate variable.
+func NewOptional[T any](t T) Optional[T] {
+	return Optional[T]{value: t, state: optionalValue}
+}
+
+// NewNullOptional is a convenience helper to allow constructing an Optional
+// with an explicit `null`.
+func NewNullOptional[T any]() Optional[T] {
+	return Optional[T]{state: optionalNull}
+}
+
+// Get retrieves the underlying value, if present, and returns an error if the value was not present
+func (o Optional[T]) Get() (T, error) {
+	var empty T
+	if o.IsNull() {
+		return empty, errors.New("value is null")
+	}
+	if !o.IsSpecified() {
+		return empty, errors.New("value is not specified")
+	}
+	return o.value, nil
+}
+
+// Set sets the underlying value to a given value
+func (o *Optional[T]) Set(value T) {
+	*o = Optional[T]{value: value, state: optionalValue}
+}
+
+// SetInterface sets the underlying value from an empty interface,
+// performing a type assertion to T.
+func (o *Optional[T]) SetInterface(value interface{}) {
+	o.Set(value.(T))
+}
+
+// IsNull indicates whether the field was sent, and had a value of `null`
+func (o Optional[T]) IsNull() bool {
+	return o.state == optionalNull
+}
+
+// SetNull sets the value to an explicit `null`
+func (o *Optional[T]) SetNull() {
+	*o = Optional[T]{state: optionalNull}
+}
+
+// IsSpecified indicates whether the field was sent
+func (o Optional[T]) IsSpecified() bool {
+	return o.state != optionalUnspecified
+}
+
+// SetUnspecified sets the value to be absent from the serialized payload
+func (o *Optional[T]) SetUnspecified() {
+	*o = Optional[T]{}
+}
+
+// optionalAttr is implemented by *Optional[T] for every T, for the encoder
+// and decoder to recognise Optional fields without knowing T.
+type optionalAttr interface {
+	IsNull() bool
+	IsSpecified() bool
+	SetNull()
+	// reflectValue returns the value of the Optional, of type T.
+	reflectValue() reflect.Value
+	// setReflectValue sets the Optional to v, of type T.
+	setReflectValue(v reflect.Value)
+}
+
+func (o *Optional[T]) reflectValue() reflect.Value {
+	return reflect.ValueOf(&o.value).Elem()
+}
+
+func (o *Optional[T]) setReflectValue(v reflect.Value) {
+	o.reflectValue().Set(v)
+	o.state = optionalValue
+}
+
+var optionalAttrType = reflect.TypeOf((*optionalAttr)(nil)).Elem()
+
+// isOptional reports whether t is an Optional type.
+func isOptional(t reflect.Type) bool {
+	return t.Kind() == reflect.Struct && reflect.PtrTo(t).Implements(optionalAttrType)
+}
+
+// optionalOf returns the Optional v holds, which is a copy when v cannot be
+// addressed.
+func optionalOf(v reflect.Value) optionalAttr {
+	if !v.CanAddr() {
+		c := reflect.New(v.Type()).Elem()
+		c.Set(v)
+		v = c
+	}
+	return v.Addr().Interface().(optionalAttr)
+}
+
+// MarshalJSON implements json.Marshaler, so that a NullableAttr used outside
+// of jsonapi is marshaled as its value, or as `null` when it is an explicit
+// null. An unspecified NullableAttr is marshaled as `null` too; add
+// `omitempty` to the `json` tag of the field to leave it out instead.
+func (t NullableAttr[T]) MarshalJSON() ([]byte, error) {
+	if !t.IsSpecified() || t.IsNull() {
+		return []byte("null"), nil
+	}
+	return json.Marshal(t[true])
+}
+
+// UnmarshalJSON implements json.Unmarshaler, setting the NullableAttr to an
+// explicit null for `null`, and to the value otherwise. A NullableAttr whose
+// member is absent is left unspecified.
+func (t *NullableAttr[T]) UnmarshalJSON(data []byte) error {
+	if isJSONNull(data) {
+		t.SetNull()
+		return nil
+	}
+
+	var value T
+	if err := json.Unmarshal(data, &value); err != nil {
+		return err
+	}
+	t.Set(value)
+	return nil
+}
+
+// IsZero reports whether the NullableAttr is unspecified, for the `omitzero`
+// option of `json` tags.
+func (t NullableAttr[T]) IsZero() bool {
+	return !t.IsSpecified()
+}
+
+// Scan implements sql.Scanner, setting the NullableAttr to an explicit null
+// for a NULL column, and to the value read otherwise, converted as
+// database/sql converts the values it scans.
+func (t *NullableAttr[T]) Scan(src interface{}) error {
+	if src == nil {
+		t.SetNull()
+		return nil
+	}
+
+	var value T
+	if err := scanValue(reflect.ValueOf(&value).Elem(), src); err != nil {
+		return err
+	}
+	t.Set(value)
+	return nil
+}
+
+// Value implements driver.Valuer, storing an unspecified or explicitly null
+// NullableAttr as NULL.
+func (t NullableAttr[T]) Value() (driver.Value, error) {
+	if !t.IsSpecified() || t.IsNull() {
+		return nil, nil
+	}
+	return driver.DefaultParameterConverter.ConvertValue(t[true])
+}
+
+// MarshalJSON implements json.Marshaler as NullableAttr does. As an Optional
+// is a struct, use the `omitzero` option of `json` tags to leave out an
+// unspecified one.
+func (o Optional[T]) MarshalJSON() ([]byte, error) {
+	if !o.IsSpecified() || o.IsNull() {
+		return []byte("null"), nil
+	}
+	return json.Marshal(o.value)
+}
+
+// UnmarshalJSON implements json.Unmarshaler as NullableAttr does.
+func (o *Optional[T]) UnmarshalJSON(data []byte) error {
+	if isJSONNull(data) {
+		o.SetNull()
+		return nil
+	}
+
+	var value T
+	if err := json.Unmarshal(data, &value); err != nil {
+		return err
+	}
+	o.Set(value)
+	return nil
+}
+
+// IsZero reports whether the Optional is unspecified, for the `omitzero`
+// option of `json` tags.
+func (o Optional[T]) IsZero() bool {
+	return !o.IsSpecified()
+}
+
+// Scan implements sql.Scanner as NullableAttr does.
+func (o *Optional[T]) Scan(src interface{}) error {
+	if src == nil {
+		o.SetNull()
+		return nil
+	}
+
+	var value T
+	if err := scanValue(reflect.ValueOf(&value).Elem(), src); err != nil {
+		return err
+	}
+	o.Set(value)
+	return nil
+}
+
+// Value implements driver.Valuer as NullableAttr does.
+func (o Optional[T]) Value() (driver.Value, error) {
+	if !o.IsSpecified() || o.IsNull() {
+		return nil, nil
+	}
+	return driver.DefaultParameterConverter.ConvertValue(o.value)
+}
+
+func isJSONNull(data []byte) bool {
+	return bytes.Equal(bytes.TrimSpace(data), []byte("null"))
+}
+
+// scanValue sets dest to src, a value read by a database driver, converting
+// it as database/sql does for the destinations passed to Rows.Scan: through
+// dest's own sql.Scanner, by assignment, or by parsing its text into a
+// string, boolean or numeric dest.
+func scanValue(dest reflect.Value, src interface{}) error {
+	if scanner, ok := dest.Addr().Interface().(sql.Scanner); ok {
+		return scanner.Scan(src)
+	}
+
+	// Drivers may reuse the memory of the bytes they return
+	if b, ok := src.([]byte); ok && dest.Kind() == reflect.Slice && dest.Type().Elem().Kind() == reflect.Uint8 {
+		dest.SetBytes(append([]byte(nil), b...))
+		return nil
+	}
+
+	if v := reflect.ValueOf(src); v.Type().AssignableTo(dest.Type()) {
+		dest.Set(v)
+		return nil
+	}
+
+	var s string
+	switch src := src.(type) {
+	case string:
+		s = src
+	case []byte:
+		s = string(src)
+	case int64:
+		s = strconv.FormatInt(src, 10)
+	case float64:
+		s = strconv.FormatFloat(src, 'g', -1, 64)
+	case bool:
+		s = strconv.FormatBool(src)
+	default:
+		return fmt.Errorf("cannot scan %T into %s", src, dest.Type())
+	}
+
+	var err error
+	switch dest.Kind() {
+	case reflect.String:
+		dest.SetString(s)
+	case reflect.Bool:
+		var b bool
+		if b, err = strconv.ParseBool(s); err == nil {
+			dest.SetBool(b)
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		var i int64
+		if i, err = strconv.ParseInt(s, 10, dest.Type().Bits()); err == nil {
+			dest.SetInt(i)
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		var u uint64
+		if u, err = strconv.ParseUint(s, 10, dest.Type().Bits()); err == nil {
+			dest.SetUint(u)
+		}
+	case reflect.Float32, reflect.Float64:
+		var f float64
+		if f, err = strconv.ParseFloat(s, dest.Type().Bits()); err == nil {
+			dest.SetFloat(f)
+		}
+	default:
+		return fmt.Errorf("cannot scan %T into %s", src, dest.Type())
+	}
+
+	if err != nil {
+		return fmt.Errorf("cannot scan %T %q into %s: %w", src, s, dest.Type(), err)
+	}
+	return nil
+}
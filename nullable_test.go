@@ -0,0 +1,270 @@
+package jsonapi
+
+import (
+	"bytes"
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type patchWithNullableAttrs struct {
+	ID          int                  `jsonapi:"primary,patches"`
+	Name        NullableAttr[string] `jsonapi:"attr,name,omitempty"`
+	Description NullableAttr[string] `jsonapi:"attr,description,omitempty"`
+	Count       NullableAttr[int]    `jsonapi:"attr,count,omitempty"`
+	Total       NullableAttr[int64]  `jsonapi:"attr,total,omitempty"`
+	Enabled     NullableAttr[bool]   `jsonapi:"attr,enabled,omitempty"`
+	Archived    NullableAttr[bool]   `jsonapi:"attr,archived,omitempty"`
+}
+
+type patchWithOptionals struct {
+	ID          int              `jsonapi:"primary,patches"`
+	Name        Optional[string] `jsonapi:"attr,name"`
+	Description Optional[string] `jsonapi:"attr,description"`
+	Count       Optional[int]    `jsonapi:"attr,count"`
+	Total       Optional[int64]  `jsonapi:"attr,total"`
+	Enabled     Optional[bool]   `jsonapi:"attr,enabled"`
+	Archived    Optional[bool]   `jsonapi:"attr,archived"`
+}
+
+// largePatchPayload returns a document of n resources, each setting every
+// attribute of the patch models, half of them to null.
+func largePatchPayload(n int) []byte {
+	resources := make([]string, n)
+	for i := range resources {
+		resources[i] = fmt.Sprintf(`{"type": "patches", "id": "%d", "attributes": {
+			"name": "patch %d", "description": null, "count": %d,
+			"total": null, "enabled": true, "archived": null
+		}}`, i+1, i, i)
+	}
+	return []byte(`{"data": [` + strings.Join(resources, ",") + `]}`)
+}
+
+func benchmarkUnmarshalPatch(b *testing.B, t reflect.Type) {
+	payload := largePatchPayload(1000)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := UnmarshalManyPayload(bytes.NewReader(payload), t); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkUnmarshalPatch_NullableAttr(b *testing.B) {
+	benchmarkUnmarshalPatch(b, reflect.TypeOf(new(patchWithNullableAttrs)))
+}
+
+func BenchmarkUnmarshalPatch_Optional(b *testing.B) {
+	benchmarkUnmarshalPatch(b, reflect.TypeOf(new(patchWithOptionals)))
+}
+
+func benchmarkMarshalPatch(b *testing.B, t reflect.Type) {
+	decoded, err := UnmarshalManyPayload(bytes.NewReader(largePatchPayload(1000)), t)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	models := reflect.MakeSlice(reflect.SliceOf(t), 0, len(decoded))
+	for _, model := range decoded {
+		models = reflect.Append(models, reflect.ValueOf(model))
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := MarshalPayload(&bytes.Buffer{}, models.Interface()); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkMarshalPatch_NullableAttr(b *testing.B) {
+	benchmarkMarshalPatch(b, reflect.TypeOf(new(patchWithNullableAttrs)))
+}
+
+func BenchmarkMarshalPatch_Optional(b *testing.B) {
+	benchmarkMarshalPatch(b, reflect.TypeOf(new(patchWithOptionals)))
+}
+
+func TestOptional_equality(t *testing.T) {
+	if NewOptional("a") != NewOptional("a") || NewOptional("a") == NewOptional("b") {
+		t.Fatal("Expected Optionals holding equal values to be equal")
+	}
+	if NewNullOptional[string]() == (Optional[string]{}) {
+		t.Fatal("Expected an explicit null to differ from unspecified")
+	}
+
+	var o Optional[int]
+	o.Set(3)
+	o.SetUnspecified()
+	if o != (Optional[int]{}) {
+		t.Fatalf("Expected SetUnspecified to reset the Optional, got %+v", o)
+	}
+}
+
+type patchRow struct {
+	Name  NullableAttr[string]    `json:"name,omitempty"`
+	Count NullableAttr[int]       `json:"count,omitempty"`
+	Born  NullableAttr[time.Time] `json:"born,omitempty"`
+}
+
+func TestNullableAttr_JSON(t *testing.T) {
+	row := patchRow{Count: NewNullNullableAttr[int]()}
+	row.Name.Set("Zorro")
+
+	out, err := json.Marshal(row)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := `{"name":"Zorro","count":null}`; string(out) != expected {
+		t.Fatalf("Was expecting %s, got %s", expected, out)
+	}
+
+	var decoded patchRow
+	if err := json.Unmarshal(out, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if name, err := decoded.Name.Get(); err != nil || name != "Zorro" {
+		t.Fatalf("Was expecting the name to be set, got %q, %v", name, err)
+	}
+	if !decoded.Count.IsNull() {
+		t.Fatal("Was expecting count to be an explicit null")
+	}
+	if decoded.Born.IsSpecified() {
+		t.Fatal("Was expecting born to be unspecified")
+	}
+
+	if err := json.Unmarshal([]byte(`{"count":"three"}`), &decoded); err == nil {
+		t.Fatal("Was expecting an error for a value of the wrong type")
+	}
+}
+
+func TestOptional_JSON(t *testing.T) {
+	out, err := json.Marshal([]Optional[int]{NewOptional(3), NewNullOptional[int](), {}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := `[3,null,null]`; string(out) != expected {
+		t.Fatalf("Was expecting %s, got %s", expected, out)
+	}
+
+	var decoded []Optional[int]
+	if err := json.Unmarshal(out, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if decoded[0] != NewOptional(3) || decoded[1] != NewNullOptional[int]() || decoded[2] != NewNullOptional[int]() {
+		t.Fatalf("Was expecting a value and two nulls, got %+v", decoded)
+	}
+}
+
+type scannedCode string
+
+func (c *scannedCode) Scan(src interface{}) error {
+	s, ok := src.(string)
+	if !ok {
+		return fmt.Errorf("unexpected %T", src)
+	}
+	*c = scannedCode(strings.ToUpper(s))
+	return nil
+}
+
+func TestNullableAttr_Scan(t *testing.T) {
+	born := time.Date(2016, 8, 17, 8, 27, 12, 0, time.UTC)
+
+	for _, tc := range []struct {
+		desc     string
+		dest     interface{ Scan(interface{}) error }
+		src      interface{}
+		expected interface{}
+		isNull   bool
+		err      bool
+	}{
+		{desc: "null", dest: new(NullableAttr[int]), src: nil, isNull: true},
+		{desc: "int64", dest: new(NullableAttr[int]), src: int64(42), expected: 42},
+		{desc: "int64ToFloat", dest: new(NullableAttr[float64]), src: int64(42), expected: float64(42)},
+		{desc: "int64Overflow", dest: new(NullableAttr[int8]), src: int64(300), err: true},
+		{desc: "bytesToString", dest: new(NullableAttr[string]), src: []byte("Zorro"), expected: "Zorro"},
+		{desc: "bytesToInt", dest: new(NullableAttr[int]), src: []byte("42"), expected: 42},
+		{desc: "bytes", dest: new(NullableAttr[[]byte]), src: []byte("raw"), expected: []byte("raw")},
+		{desc: "int64ToBool", dest: new(NullableAttr[bool]), src: int64(1), expected: true},
+		{desc: "time", dest: new(NullableAttr[time.Time]), src: born, expected: born},
+		{desc: "scanner", dest: new(NullableAttr[scannedCode]), src: "abc", expected: scannedCode("ABC")},
+		{desc: "mismatch", dest: new(NullableAttr[time.Time]), src: int64(1), err: true},
+		{desc: "optional", dest: new(Optional[string]), src: []byte("Zorro"), expected: "Zorro"},
+		{desc: "optionalNull", dest: new(Optional[string]), src: nil, isNull: true},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			err := tc.dest.Scan(tc.src)
+			if tc.err {
+				if err == nil {
+					t.Fatal("Was expecting an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			attr := reflect.ValueOf(tc.dest).Interface().(interface{ IsNull() bool })
+			if attr.IsNull() != tc.isNull {
+				t.Fatalf("Was expecting IsNull to be %v", tc.isNull)
+			}
+			if tc.isNull {
+				return
+			}
+			got := reflect.ValueOf(tc.dest).MethodByName("Get").Call(nil)[0].Interface()
+			if !reflect.DeepEqual(got, tc.expected) {
+				t.Fatalf("Was expecting %#v, got %#v", tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestNullableAttr_Scan_copiesBytes(t *testing.T) {
+	src := []byte("raw")
+
+	var attr NullableAttr[[]byte]
+	if err := attr.Scan(src); err != nil {
+		t.Fatal(err)
+	}
+	src[0] = 'w'
+
+	if got, _ := attr.Get(); string(got) != "raw" {
+		t.Fatalf("Was expecting the scanned bytes to be copied, got %q", got)
+	}
+}
+
+func TestNullableAttr_Value(t *testing.T) {
+	born := time.Date(2016, 8, 17, 8, 27, 12, 0, time.UTC)
+
+	for _, tc := range []struct {
+		desc     string
+		valuer   driver.Valuer
+		expected driver.Value
+	}{
+		{desc: "unspecified", valuer: NullableAttr[int]{}, expected: nil},
+		{desc: "null", valuer: NewNullNullableAttr[int](), expected: nil},
+		{desc: "int", valuer: NewNullableAttrWithValue(42), expected: int64(42)},
+		{desc: "uint8", valuer: NewNullableAttrWithValue(uint8(7)), expected: int64(7)},
+		{desc: "string", valuer: NewNullableAttrWithValue("Zorro"), expected: "Zorro"},
+		{desc: "time", valuer: NewNullableAttrWithValue(born), expected: born},
+		{desc: "optional", valuer: NewOptional(1.5), expected: 1.5},
+		{desc: "optionalUnspecified", valuer: Optional[float64]{}, expected: nil},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			got, err := tc.valuer.Value()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tc.expected) {
+				t.Fatalf("Was expecting %#v, got %#v", tc.expected, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,105 @@
+package jsonapi
+
+// UnmarshalOption configures optional decoding behaviour of UnmarshalPayload
+// and UnmarshalManyPayload.
+type UnmarshalOption func(*unmarshalOptions)
+
+type unmarshalOptions struct {
+	collectErrors          bool
+	disallowUnknownMembers bool
+
+	// maxIncludeDepth only applies when limitIncludeDepth is set, so that a
+	// limit of 0 can be told apart from no limit.
+	maxIncludeDepth   int
+	limitIncludeDepth bool
+
+	// operation is the operation the request document is for, if known.
+	operation OperationCode
+}
+
+// CollectAllErrors makes decoding carry on past members that cannot be
+// decoded instead of stopping at the first one. Every field that could be
+// decoded is still populated, and all failures are returned together as
+// UnmarshalErrors once the whole document has been walked.
+func CollectAllErrors() UnmarshalOption {
+	return func(o *unmarshalOptions) {
+		o.collectErrors = true
+	}
+}
+
+// DisallowUnknownMembers makes decoding reject members of the request document
+// that would otherwise be silently ignored: attributes and relationships with
+// no matching `attr`, `relation` or `polyrelation` tag on the model, top-level,
+// resource object and relationship object members the JSON API spec does not
+// define, and related resource types that a `polyrelation` choice struct has no
+// field for. Each one is reported as an *UnmarshalError wrapping
+// ErrUnknownMember or ErrUnknownType.
+func DisallowUnknownMembers() UnmarshalOption {
+	return func(o *unmarshalOptions) {
+		o.disallowUnknownMembers = true
+	}
+}
+
+// MaxIncludeDepth limits how many relationships deep included resources are
+// resolved, counting from the primary data. Relationships of resources found
+// at that depth are decoded from their resource linkage alone, leaving only
+// the ID set on the related models, even when the related resources are in
+// "included". A depth of 0 resolves no relationships from "included" at all.
+//
+// Without this option every included resource reachable from the primary
+// data is decoded; resources that refer back to one another are still only
+// decoded once.
+func MaxIncludeDepth(depth int) UnmarshalOption {
+	return func(o *unmarshalOptions) {
+		o.maxIncludeDepth = depth
+		o.limitIncludeDepth = true
+	}
+}
+
+// ForOperation tells decoding which operation the request document is for:
+// OperationAdd for a request creating resources, such as a POST, or
+// OperationUpdate for one updating them, such as a PATCH. The `required`,
+// `readonly` and `createonly` options of `attr`, `relation` and
+// `polyrelation` tags are only enforced, on the resources of the primary
+// data, when the operation is known:
+//
+//   - `required` fields whose member is missing when creating are reported
+//     as an *UnmarshalError wrapping ErrMissingMember, with a 422 status.
+//   - `readonly` fields whose member is sent, and `createonly` ones whose
+//     member is sent when updating, are reported as an *UnmarshalError
+//     wrapping ErrReadOnlyMember, with a 403 status.
+//
+// ApplyPatch decodes for OperationUpdate, and Operation.Unmarshal for the
+// operation's own code, unless told otherwise.
+func ForOperation(op OperationCode) UnmarshalOption {
+	return func(o *unmarshalOptions) {
+		o.operation = op
+	}
+}
+
+// MarshalOption configures optional encoding behaviour of MarshalPayload and
+// Marshal.
+type MarshalOption func(*marshalOptions)
+
+type marshalOptions struct {
+	// maxRelationshipDepth only applies when limitRelationshipDepth is set,
+	// so that a limit of 0 can be told apart from no limit.
+	maxRelationshipDepth   int
+	limitRelationshipDepth bool
+}
+
+// MaxRelationshipDepth limits how many relationships deep related resources
+// are encoded, counting from the primary data. Relationships of resources
+// found at that depth are encoded as resource linkage only, and the resources
+// they lead to are left out of "included". A depth of 0 encodes the
+// relationships of the primary data as linkage only.
+//
+// Without this option every resource reachable from the primary data is
+// encoded. Each one is encoded once, and relationships leading back to a
+// resource already encoded are encoded as linkage only.
+func MaxRelationshipDepth(depth int) MarshalOption {
+	return func(o *marshalOptions) {
+		o.maxRelationshipDepth = depth
+		o.limitRelationshipDepth = true
+	}
+}
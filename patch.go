@@ -0,0 +1,422 @@
+package jsonapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+)
+
+// ErrPatchMismatch is returned by MarshalPatch when the models it is given
+// are not of the same type, or are not the same resource, and by ApplyPatch
+// when the document is for another resource than the model.
+var ErrPatchMismatch = errors.New("models should be the same resource")
+
+// MarshalPatch writes the document of a PATCH request that updates the
+// resource of the model before to the model after. Its "data" holds the
+// type and ID of the resource, and only those attributes and relationships
+// whose encoding differs between the two models. A member that after leaves
+// out, such as an `omitempty` field that was cleared, is written as `null`,
+// or as an empty array for a to-many relationship. Related models are
+// written as resource linkage only.
+//
+//	func UpdateBlog(client *http.Client, before, after *Blog) error {
+//		body := new(bytes.Buffer)
+//		if err := jsonapi.MarshalPatch(body, before, after); err != nil {
+//			return err
+//		}
+//		// ...
+//	}
+//
+// A NullableAttr, Optional or NullableRelationship field that is
+// unspecified in after is left out, as not being part of the update, as are
+// `readonly` and `createonly` fields; `writeonly` ones are written. before
+// and after should be pointers to structs of the same type, with the same
+// ID; otherwise an error wrapping ErrPatchMismatch is returned.
+func MarshalPatch(w io.Writer, before, after interface{}, opts ...MarshalOption) error {
+	payload, err := marshalPatch(before, after, opts)
+	if err != nil {
+		return err
+	}
+
+	return json.NewEncoder(w).Encode(payload)
+}
+
+func marshalPatch(before, after interface{}, opts []MarshalOption) (*OnePayload, error) {
+	beforeValue, afterValue := reflect.ValueOf(before), reflect.ValueOf(after)
+	for _, value := range []reflect.Value{beforeValue, afterValue} {
+		if value.Kind() != reflect.Ptr || value.Elem().Kind() != reflect.Struct {
+			return nil, ErrUnexpectedType
+		}
+	}
+	if beforeValue.Type() != afterValue.Type() {
+		return nil, fmt.Errorf("%w: %s and %s are different types", ErrPatchMismatch, beforeValue.Type(), afterValue.Type())
+	}
+
+	// Related models are encoded as linkage only
+	opts = append(opts[:len(opts):len(opts)], MaxRelationshipDepth(0))
+	e := newEncoder(true, opts)
+
+	node := new(Node)
+	for _, structField := range modelFields(beforeValue.Elem().Type()) {
+		args := strings.Split(structField.Tag.Get(annotationJSONAPI), annotationSeparator)
+		annotation := args[0]
+
+		if annotation != annotationClientID && len(args) < 2 {
+			return nil, ErrBadJSONAPIStructTag
+		}
+
+		// Fields promoted from a nil embedded struct pointer are left out
+		beforeField, inBefore := fieldByIndex(beforeValue.Elem(), structField.Index, false)
+		afterField, inAfter := fieldByIndex(afterValue.Elem(), structField.Index, false)
+
+		switch annotation {
+		case annotationPrimary:
+			var beforeID, afterID string
+			var err error
+			if inBefore {
+				if beforeID, err = primaryID(beforeField); err != nil {
+					return nil, err
+				}
+			}
+			if inAfter {
+				if afterID, err = primaryID(afterField); err != nil {
+					return nil, err
+				}
+			}
+			if beforeID != afterID {
+				return nil, fmt.Errorf("%w: IDs %q and %q differ", ErrPatchMismatch, beforeID, afterID)
+			}
+			node.Type, node.ID = args[1], afterID
+
+		case annotationAttribute:
+			if inAfter && isUnspecified(afterField) || !updatable(args) {
+				continue
+			}
+			// Write-only attributes are sent in requests
+			args = withoutTagOption(args, annotationWriteOnly)
+
+			beforeNode, afterNode := new(Node), new(Node)
+			if inBefore {
+				if err := visitModelNodeAttribute(args, beforeNode, beforeField); err != nil {
+					return nil, err
+				}
+			}
+			if inAfter {
+				if err := visitModelNodeAttribute(args, afterNode, afterField); err != nil {
+					return nil, err
+				}
+			}
+
+			value, changed, err := patchMember(args[1], beforeNode.Attributes, afterNode.Attributes)
+			if err != nil {
+				return nil, fmt.Errorf("failed to compare attribute %q: %w", args[1], err)
+			}
+			if !changed {
+				continue
+			}
+			if value == nil {
+				value = json.RawMessage("null")
+			}
+			if node.Attributes == nil {
+				node.Attributes = make(map[string]interface{})
+			}
+			node.Attributes[args[1]] = value
+
+		case annotationRelation, annotationPolyRelation:
+			if inAfter && isUnspecified(afterField) || !updatable(args) {
+				continue
+			}
+
+			beforeNode, afterNode := new(Node), new(Node)
+			if inBefore {
+				if err := e.visitModelNodeRelation(before, annotation, args, beforeNode, beforeField); err != nil {
+					return nil, err
+				}
+			}
+			if inAfter {
+				if err := e.visitModelNodeRelation(after, annotation, args, afterNode, afterField); err != nil {
+					return nil, err
+				}
+			}
+
+			value, changed, err := patchMember(args[1], linkageOnly(beforeNode.Relationships), linkageOnly(afterNode.Relationships))
+			if err != nil {
+				return nil, fmt.Errorf("failed to compare relationship %q: %w", args[1], err)
+			}
+			if !changed {
+				continue
+			}
+			if value == nil {
+				if _, ok := beforeNode.Relationships[args[1]].(*RelationshipManyNode); ok {
+					value = &RelationshipManyNode{Data: []*Node{}}
+				} else {
+					value = &RelationshipOneNode{}
+				}
+			}
+			if node.Relationships == nil {
+				node.Relationships = make(map[string]interface{})
+			}
+			node.Relationships[args[1]] = value
+		}
+	}
+
+	return &OnePayload{Data: node}, nil
+}
+
+// patchMember compares the encodings of the member key in before and after,
+// and returns its value in after if they differ, which is nil if after
+// leaves it out.
+func patchMember(key string, before, after map[string]interface{}) (interface{}, bool, error) {
+	beforeValue, inBefore := before[key]
+	afterValue, inAfter := after[key]
+	if !inAfter {
+		return nil, inBefore, nil
+	}
+	if !inBefore {
+		return afterValue, true, nil
+	}
+
+	beforeJSON, err := json.Marshal(beforeValue)
+	if err != nil {
+		return nil, false, err
+	}
+	afterJSON, err := json.Marshal(afterValue)
+	if err != nil {
+		return nil, false, err
+	}
+	return afterValue, !bytes.Equal(beforeJSON, afterJSON), nil
+}
+
+// linkageOnly strips the links and meta from relationships, which only
+// belong in responses, leaving their resource linkage.
+func linkageOnly(relationships map[string]interface{}) map[string]interface{} {
+	for key, relationship := range relationships {
+		switch r := relationship.(type) {
+		case *RelationshipOneNode:
+			relationships[key] = &RelationshipOneNode{Data: r.Data}
+		case *RelationshipManyNode:
+			relationships[key] = &RelationshipManyNode{Data: r.Data}
+		}
+	}
+	return relationships
+}
+
+// updatable reports whether a client may update the member of a field with
+// the jsonapi tag args.
+func updatable(args []string) bool {
+	return !hasTagOption(args, annotationReadOnly) && !hasTagOption(args, annotationCreateOnly)
+}
+
+// isUnspecified reports whether v is a NullableAttr, Optional or
+// NullableRelationship that is neither null nor set to a value.
+func isUnspecified(v reflect.Value) bool {
+	switch {
+	case strings.HasPrefix(v.Type().Name(), "NullableAttr["),
+		strings.HasPrefix(v.Type().Name(), "NullableRelationship["):
+		return v.Len() == 0
+	case isOptional(v.Type()):
+		return !optionalOf(v).IsSpecified()
+	}
+	return false
+}
+
+// PatchFields is the set of attributes and relationships of a resource that
+// ApplyPatch found in a document, as JSON Pointers such as
+// /data/attributes/title and /data/relationships/author.
+type PatchFields map[string]bool
+
+// HasAttribute reports whether the named attribute was sent.
+func (f PatchFields) HasAttribute(name string) bool {
+	return f[jsonPointer("/data", "attributes", name)]
+}
+
+// HasRelationship reports whether the resource linkage of the named
+// relationship was sent.
+func (f PatchFields) HasRelationship(name string) bool {
+	return f[jsonPointer("/data", "relationships", name)]
+}
+
+// ApplyPatch decodes the document of a PATCH request onto existing, a model
+// already holding the stored resource, and returns the attributes and
+// relationships the document sent. Only the fields of those members are
+// overwritten; every other field keeps its value.
+//
+//	func UpdateBlog(w http.ResponseWriter, r *http.Request) {
+//		blog := loadBlog(r)
+//
+//		fields, err := jsonapi.ApplyPatch(r.Body, blog)
+//		if err != nil {
+//			http.Error(w, err.Error(), http.StatusBadRequest)
+//			return
+//		}
+//		if fields.HasAttribute("title") {
+//			// ...
+//		}
+//	}
+//
+// Unlike UnmarshalPayload, an attribute sent as null clears its field even
+// when the field is not a pointer, a to-one relationship sent as null clears
+// its field, and a relationship object without "data" is not applied. The
+// access rules of existing's fields are enforced for OperationUpdate, as with
+// ForOperation.
+//
+// The document must be for the resource existing holds: when both have an
+// ID and they differ, an *UnmarshalError wrapping ErrPatchMismatch is
+// returned. The document is applied as a whole or not at all: when a member
+// cannot be decoded, breaks an access rule or fails validation, existing is
+// left untouched, and the PatchFields are still returned along with the
+// error.
+//
+// existing interface{} should be a pointer to a struct.
+func ApplyPatch(in io.Reader, existing interface{}, opts ...UnmarshalOption) (PatchFields, error) {
+	value := reflect.ValueOf(existing)
+	if value.Kind() != reflect.Ptr || value.Elem().Kind() != reflect.Struct {
+		return nil, ErrUnexpectedType
+	}
+
+	payload := new(OnePayload)
+	d := newDecoder(opts)
+	d.patch = true
+	if d.opts.operation == "" {
+		d.opts.operation = OperationUpdate
+	}
+
+	if err := d.decode(in, payload); err != nil {
+		return nil, err
+	}
+
+	if payload.Data != nil && payload.Data.ID != "" {
+		if stored := resourceIdentifier(existing); stored != nil && stored.ID != payload.Data.ID {
+			return nil, &UnmarshalError{
+				Pointer: "/data/id",
+				Status:  http.StatusConflict,
+				Err:     fmt.Errorf("%w: the document is for ID %q, not %q", ErrPatchMismatch, payload.Data.ID, stored.ID),
+			}
+		}
+	}
+
+	if err := d.index(payload.Included); err != nil {
+		return nil, err
+	}
+
+	// The document is decoded into a copy of existing, which is only
+	// updated once all of it has been applied
+	patched := reflect.New(value.Elem().Type())
+	patched.Elem().Set(value.Elem())
+	copyEmbedded(patched.Elem(), map[reflect.Type]bool{})
+	if payload.Data != nil && (payload.Data.ID != "" || payload.Data.Lid != "") {
+		// Relationships leading back to the resource are assigned existing
+		d.visited = map[string]reflect.Value{resourceKey(payload.Data): value}
+	}
+
+	err := d.unmarshalPrimary(payload.Data, patched, resourceAt("/data"))
+	if err == nil {
+		err = d.err()
+	}
+	fields := patchFields(payload.Data, existing)
+	if err != nil {
+		return fields, err
+	}
+
+	applyEmbedded(value.Elem(), patched.Elem(), map[reflect.Type]bool{})
+	return fields, nil
+}
+
+// embeddedPointers returns the non-nil pointers to anonymous embedded
+// structs of the struct v that its fields may be promoted through, as
+// settable fields of v, except those to a struct type already in visited.
+func embeddedPointers(v reflect.Value, visited map[reflect.Type]bool) []reflect.Value {
+	var pointers []reflect.Value
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Type().Field(i)
+		if !field.Anonymous || field.Tag.Get(annotationJSONAPI) != "" {
+			continue
+		}
+
+		fieldValue := v.Field(i)
+		switch {
+		case fieldValue.Kind() == reflect.Struct:
+			pointers = append(pointers, embeddedPointers(fieldValue, visited)...)
+		case fieldValue.Kind() == reflect.Ptr && fieldValue.Type().Elem().Kind() == reflect.Struct:
+			if !fieldValue.IsNil() && fieldValue.CanSet() && !visited[fieldValue.Type().Elem()] {
+				visited[fieldValue.Type().Elem()] = true
+				pointers = append(pointers, fieldValue)
+			}
+		}
+	}
+	return pointers
+}
+
+// copyEmbedded points the embedded struct pointers of the struct v at copies
+// of the structs they point to, so that promoted fields can be decoded into v
+// without writing into structs it shares with another model.
+func copyEmbedded(v reflect.Value, visited map[reflect.Type]bool) {
+	for _, pointer := range embeddedPointers(v, visited) {
+		copied := reflect.New(pointer.Type().Elem())
+		copied.Elem().Set(pointer.Elem())
+		copyEmbedded(copied.Elem(), visited)
+		pointer.Set(copied)
+	}
+}
+
+// applyEmbedded sets the struct dst to src, a copy of it made with
+// copyEmbedded, writing the embedded structs of src into those dst already
+// points to rather than replacing its pointers.
+func applyEmbedded(dst, src reflect.Value, visited map[reflect.Type]bool) {
+	pointers := embeddedPointers(dst, visited)
+	originals := make([]reflect.Value, len(pointers))
+	for i, pointer := range pointers {
+		originals[i] = reflect.ValueOf(pointer.Interface())
+	}
+
+	dst.Set(src)
+	for i, pointer := range pointers {
+		applyEmbedded(originals[i].Elem(), pointer.Elem(), visited)
+		pointer.Set(originals[i])
+	}
+}
+
+// patchFields returns the members of data that have a field in model.
+func patchFields(data *Node, model interface{}) PatchFields {
+	fields := PatchFields{}
+
+	value := reflect.Indirect(reflect.ValueOf(model))
+	if data == nil || value.Kind() != reflect.Struct {
+		return fields
+	}
+
+	for _, field := range modelFields(value.Type()) {
+		args := strings.Split(field.Tag.Get(annotationJSONAPI), annotationSeparator)
+		if len(args) < 2 {
+			continue
+		}
+
+		switch args[0] {
+		case annotationAttribute:
+			if _, ok := data.Attributes[args[1]]; ok {
+				fields[jsonPointer("/data", "attributes", args[1])] = true
+			}
+		case annotationRelation, annotationPolyRelation:
+			if relationship, ok := data.Relationships[args[1]]; ok && hasLinkage(relationship) {
+				fields[jsonPointer("/data", "relationships", args[1])] = true
+			}
+		}
+	}
+	return fields
+}
+
+// hasLinkage reports whether the decoded relationship object has a "data"
+// member, even if null.
+func hasLinkage(relationship interface{}) bool {
+	object, ok := relationship.(map[string]interface{})
+	if !ok {
+		return false
+	}
+	_, ok = object["data"]
+	return ok
+}
@@ -0,0 +1,308 @@
+package jsonapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func marshalPatchMap(t *testing.T, before, after interface{}) map[string]interface{} {
+	t.Helper()
+
+	out := bytes.NewBuffer(nil)
+	if err := MarshalPatch(out, before, after); err != nil {
+		t.Fatal(err)
+	}
+
+	var document struct {
+		Data map[string]interface{} `json:"data"`
+	}
+	if err := json.Unmarshal(out.Bytes(), &document); err != nil {
+		t.Fatal(err)
+	}
+	return document.Data
+}
+
+func TestMarshalPatch_attributes(t *testing.T) {
+	description := "first edition"
+	pages := uint(100)
+	before := &Book{
+		ID:          1,
+		Author:      "Jane",
+		ISBN:        "0-00",
+		Title:       "Draft",
+		Description: &description,
+		Pages:       &pages,
+		Tags:        []string{"a"},
+	}
+	after := &Book{
+		ID:     1,
+		Author: "Jane Doe",
+		ISBN:   "0-00",
+		Pages:  &pages,
+		Tags:   []string{"a", "b"},
+	}
+
+	data := marshalPatchMap(t, before, after)
+	if data["type"] != "books" || data["id"] != "1" {
+		t.Fatalf("Was expecting the resource to be identified, got %v", data)
+	}
+	expected := map[string]interface{}{
+		"author":      "Jane Doe",
+		"title":       nil,
+		"description": nil,
+		"tags":        []interface{}{"a", "b"},
+	}
+	if !reflect.DeepEqual(data["attributes"], expected) {
+		t.Fatalf("Was expecting attributes %v, got %v", expected, data["attributes"])
+	}
+	if _, ok := data["relationships"]; ok {
+		t.Fatalf("Was expecting no relationships, got %v", data["relationships"])
+	}
+}
+
+func TestMarshalPatch_relationships(t *testing.T) {
+	first, second := &Comment{ID: 1, Body: "first"}, &Comment{ID: 2, Body: "second"}
+	before := &Post{ID: 1, Title: "Title", Comments: []*Comment{first, second}, LatestComment: second}
+	after := &Post{ID: 1, Title: "Title", Comments: []*Comment{first}}
+
+	data := marshalPatchMap(t, before, after)
+	if _, ok := data["attributes"]; ok {
+		t.Fatalf("Was expecting no attributes, got %v", data["attributes"])
+	}
+	expected := map[string]interface{}{
+		"comments": map[string]interface{}{
+			"data": []interface{}{map[string]interface{}{"type": "comments", "id": "1"}},
+		},
+		"latest_comment": map[string]interface{}{"data": nil},
+	}
+	if !reflect.DeepEqual(data["relationships"], expected) {
+		t.Fatalf("Was expecting relationships %v, got %v", expected, data["relationships"])
+	}
+}
+
+func TestMarshalPatch_nullable(t *testing.T) {
+	before := &WithNullableRelationships{
+		ID:       1,
+		Comments: NewNullableRelationshipWithValue([]*Comment{{ID: 1}}),
+		Hero:     NewNullableRelationshipWithValue(&OneOfMedia{Image: &Image{ID: "1"}}),
+	}
+	after := &WithNullableRelationships{
+		ID:       1,
+		Comments: NewNullNullableRelationship[[]*Comment](),
+	}
+
+	// The hero is unspecified in after, so it is not part of the update
+	data := marshalPatchMap(t, before, after)
+	expected := map[string]interface{}{
+		"comments": map[string]interface{}{"data": []interface{}{}},
+	}
+	if !reflect.DeepEqual(data["relationships"], expected) {
+		t.Fatalf("Was expecting relationships %v, got %v", expected, data["relationships"])
+	}
+
+	optionals := marshalPatchMap(t,
+		&WithOptionals{ID: 1, Name: NewOptional("a"), Count: NewOptional(1)},
+		&WithOptionals{ID: 1, Name: NewNullOptional[string]()},
+	)
+	if expected := map[string]interface{}{"name": nil}; !reflect.DeepEqual(optionals["attributes"], expected) {
+		t.Fatalf("Was expecting attributes %v, got %v", expected, optionals["attributes"])
+	}
+}
+
+func TestMarshalPatch_unchanged(t *testing.T) {
+	data := marshalPatchMap(t, &Book{ID: 1, Author: "Jane"}, &Book{ID: 1, Author: "Jane"})
+
+	expected := map[string]interface{}{"type": "books", "id": "1"}
+	if !reflect.DeepEqual(data, expected) {
+		t.Fatalf("Was expecting %v, got %v", expected, data)
+	}
+}
+
+func TestMarshalPatch_mismatch(t *testing.T) {
+	for _, tc := range []struct {
+		desc          string
+		before, after interface{}
+		expected      error
+	}{
+		{desc: "ids", before: &Book{ID: 1}, after: &Book{ID: 2}, expected: ErrPatchMismatch},
+		{desc: "types", before: &Book{ID: 1}, after: &Comment{ID: 1}, expected: ErrPatchMismatch},
+		{desc: "notPointer", before: Book{ID: 1}, after: Book{ID: 1}, expected: ErrUnexpectedType},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			err := MarshalPatch(bytes.NewBuffer(nil), tc.before, tc.after)
+			if !errors.Is(err, tc.expected) {
+				t.Fatalf("Was expecting %v, got %v", tc.expected, err)
+			}
+		})
+	}
+}
+
+func TestApplyPatch_attributes(t *testing.T) {
+	description := "first edition"
+	book := &Book{
+		ID:          1,
+		Author:      "Jane",
+		ISBN:        "0-00",
+		Title:       "Draft",
+		Description: &description,
+		Tags:        []string{"a"},
+	}
+
+	payload := `{
+		"data": {
+			"type": "books",
+			"id": "1",
+			"attributes": {
+				"author": "Jane Doe",
+				"title": null,
+				"description": null,
+				"unknown": 1
+			}
+		}
+	}`
+	fields, err := ApplyPatch(strings.NewReader(payload), book)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if book.Author != "Jane Doe" || book.Title != "" || book.Description != nil {
+		t.Fatalf("Was expecting the sent attributes to be applied, got %+v", book)
+	}
+	if book.ISBN != "0-00" || !reflect.DeepEqual(book.Tags, []string{"a"}) {
+		t.Fatalf("Was expecting the other attributes to be kept, got %+v", book)
+	}
+
+	expected := PatchFields{
+		"/data/attributes/author":      true,
+		"/data/attributes/title":       true,
+		"/data/attributes/description": true,
+	}
+	if !reflect.DeepEqual(fields, expected) {
+		t.Fatalf("Was expecting fields %v, got %v", expected, fields)
+	}
+	if !fields.HasAttribute("title") || fields.HasAttribute("isbn") {
+		t.Fatal("Was expecting HasAttribute to report the sent attributes only")
+	}
+}
+
+func TestApplyPatch_relationships(t *testing.T) {
+	comment := &Comment{ID: 1}
+	post := &Post{ID: 1, Title: "Title", Comments: []*Comment{comment}, LatestComment: comment}
+
+	payload := `{
+		"data": {
+			"type": "posts",
+			"id": "1",
+			"relationships": {
+				"latest_comment": {"data": null},
+				"comments": {"links": {"related": "/posts/1/comments"}}
+			}
+		}
+	}`
+	fields, err := ApplyPatch(strings.NewReader(payload), post)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if post.LatestComment != nil {
+		t.Fatalf("Was expecting the null relationship to be cleared, got %+v", post.LatestComment)
+	}
+	if len(post.Comments) != 1 || post.Comments[0] != comment || post.Title != "Title" {
+		t.Fatalf("Was expecting the other members to be kept, got %+v", post)
+	}
+	if !fields.HasRelationship("latest_comment") || fields.HasRelationship("comments") || len(fields) != 1 {
+		t.Fatalf("Was expecting only latest_comment to be sent, got %v", fields)
+	}
+}
+
+func TestApplyPatch_failure(t *testing.T) {
+	createdAt := time.Date(2016, 8, 17, 8, 27, 12, 0, time.UTC)
+
+	for _, tc := range []struct {
+		desc    string
+		model   func() interface{}
+		payload string
+		opts    []UnmarshalOption
+	}{
+		{
+			desc:    "invalidMember",
+			model:   func() interface{} { return &Book{ID: 1, Author: "Jane"} },
+			payload: `{"data": {"type": "books", "id": "1", "attributes": {"author": "John", "pages": "many"}}}`,
+		},
+		{
+			desc:    "invalidMember_collectAllErrors",
+			model:   func() interface{} { return &Book{ID: 1, Author: "Jane"} },
+			payload: `{"data": {"type": "books", "id": "1", "attributes": {"author": "John", "pages": "many"}}}`,
+			opts:    []UnmarshalOption{CollectAllErrors()},
+		},
+		{
+			desc:    "validation",
+			model:   func() interface{} { return &Draft{ID: "1", Title: "Draft", Words: 3} },
+			payload: `{"data": {"type": "drafts", "id": "1", "attributes": {"title": "Final", "words": -1}}}`,
+		},
+		{
+			desc: "embeddedPointer",
+			model: func() interface{} {
+				return &Widget{BaseModel: BaseModel{ID: "w1", Timestamps: &Timestamps{CreatedAt: createdAt}}, Name: "Widget"}
+			},
+			payload: `{"data": {"type": "widgets", "id": "w1", "attributes": {"created_at": "2020-01-01T00:00:00Z", "name": 1}}}`,
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			model := tc.model()
+
+			fields, err := ApplyPatch(strings.NewReader(tc.payload), model, tc.opts...)
+			if err == nil {
+				t.Fatal("Was expecting an error")
+			}
+			if len(fields) != 2 {
+				t.Fatalf("Was expecting the sent fields along with the error, got %v", fields)
+			}
+			if expected := tc.model(); !reflect.DeepEqual(model, expected) {
+				t.Fatalf("Was expecting the model to be left untouched, got %+v", model)
+			}
+		})
+	}
+}
+
+func TestApplyPatch_embeddedPointer(t *testing.T) {
+	timestamps := &Timestamps{CreatedAt: time.Date(2016, 8, 17, 8, 27, 12, 0, time.UTC)}
+	widget := &Widget{BaseModel: BaseModel{ID: "w1", Timestamps: timestamps}, Name: "Widget"}
+
+	payload := `{"data": {"type": "widgets", "id": "w1", "attributes": {"created_at": "2020-01-01T00:00:00Z", "name": "Gadget"}}}`
+	if _, err := ApplyPatch(strings.NewReader(payload), widget); err != nil {
+		t.Fatal(err)
+	}
+
+	// The patch is written into the embedded struct the model already points to
+	if widget.Timestamps != timestamps || !timestamps.CreatedAt.Equal(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Fatalf("Was expecting created_at to be applied to the embedded struct, got %+v", widget.Timestamps)
+	}
+	if widget.Name != "Gadget" {
+		t.Fatalf("Was expecting the name to be applied, got %+v", widget)
+	}
+}
+
+func TestApplyPatch_idMismatch(t *testing.T) {
+	book := &Book{ID: 1, Author: "Jane"}
+
+	payload := `{"data": {"type": "books", "id": "2", "attributes": {"author": "John"}}}`
+	_, err := ApplyPatch(strings.NewReader(payload), book)
+	if !errors.Is(err, ErrPatchMismatch) {
+		t.Fatalf("Was expecting ErrPatchMismatch, got %v", err)
+	}
+
+	var unmarshalErr *UnmarshalError
+	if !errors.As(err, &unmarshalErr) || unmarshalErr.Pointer != "/data/id" || unmarshalErr.Status != http.StatusConflict {
+		t.Fatalf("Was expecting a 409 at /data/id, got %#v", err)
+	}
+	if book.Author != "Jane" {
+		t.Fatalf("Was expecting the model to be left untouched, got %+v", book)
+	}
+}
@@ -0,0 +1,168 @@
+package jsonapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"sync"
+)
+
+var (
+	registryMu sync.RWMutex
+	// registry maps the `primary` type names of registered models to their
+	// pointer types.
+	registry = map[string]reflect.Type{}
+)
+
+// RegisterType records the types of models under the type names of their
+// `primary` annotations, so that resources of those types can be decoded
+// without naming their model up front: into `relation` fields and slices
+// typed as an interface the model implements, by UnmarshalManyPayload given
+// an interface type, and by UnmarshalAny.
+//
+//	type Media interface{ IsMedia() }
+//
+//	func init() {
+//		jsonapi.RegisterType(&Video{}, &Image{})
+//	}
+//
+//	type Post struct {
+//		ID    string  `jsonapi:"primary,posts"`
+//		Hero  Media   `jsonapi:"relation,hero"`
+//		Media []Media `jsonapi:"relation,media"`
+//	}
+//
+// Each model should be a pointer to a struct. RegisterType is meant to be
+// called from init functions, and panics if a model has no `primary`
+// annotation or another type is already registered under its type name.
+func RegisterType(models ...interface{}) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	for _, model := range models {
+		t := reflect.TypeOf(model)
+		if t == nil || t.Kind() != reflect.Ptr || t.Elem().Kind() != reflect.Struct {
+			panic(fmt.Sprintf("jsonapi: RegisterType of %T, which is not a pointer to a struct", model))
+		}
+
+		name, err := jsonapiTypeOfModel(t.Elem())
+		if err != nil {
+			panic(fmt.Sprintf("jsonapi: RegisterType of %s: %v", t, err))
+		}
+
+		if registered, ok := registry[name]; ok && registered != t {
+			panic(fmt.Sprintf("jsonapi: RegisterType of %s, but %s is already registered as %q", t, registered, name))
+		}
+		registry[name] = t
+	}
+}
+
+// registeredType returns the pointer type of the model registered under the
+// type name.
+func registeredType(name string) (reflect.Type, bool) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	t, ok := registry[name]
+	return t, ok
+}
+
+// newModel returns a new model to decode the resource data, found at loc,
+// into. t is either the pointer type of the model, or an interface type, in
+// which case the model is the registered one for the type of data, which
+// must implement t.
+func newModel(t reflect.Type, data *Node, loc location, field string) (reflect.Value, error) {
+	if t.Kind() != reflect.Interface {
+		return reflect.New(t.Elem()), nil
+	}
+
+	var name string
+	if data != nil {
+		name = data.Type
+	}
+
+	registered, ok := registeredType(name)
+	if !ok {
+		return reflect.Value{}, &UnmarshalError{
+			Pointer: jsonPointer(loc.node, "type"),
+			Field:   field,
+			Status:  http.StatusConflict,
+			Err:     fmt.Errorf("%w %q: no model is registered for it", ErrUnknownType, name),
+		}
+	}
+	if !registered.Implements(t) {
+		return reflect.Value{}, &UnmarshalError{
+			Pointer: jsonPointer(loc.node, "type"),
+			Field:   field,
+			Status:  http.StatusConflict,
+			Err:     fmt.Errorf("%w %q: %s does not implement %s", ErrUnknownType, name, registered, t),
+		}
+	}
+	return reflect.New(registered.Elem()), nil
+}
+
+// anyType is the interface type every model implements, for decoding
+// resources of any registered type.
+var anyType = reflect.TypeOf((*interface{})(nil)).Elem()
+
+// UnmarshalAny decodes a document into the models registered with
+// RegisterType for the types of its resources. For a single resource in
+// "data" it returns a pointer to the model registered for its type, and for
+// an array of resources a []interface{} holding one for each of them, in
+// order, whatever their types.
+//
+//	model, err := jsonapi.UnmarshalAny(r.Body)
+//	switch m := model.(type) {
+//	case *Video:
+//		// ...
+//	case *Image:
+//		// ...
+//	}
+//
+// A resource whose type has no registered model is reported as an
+// *UnmarshalError wrapping ErrUnknownType. As with UnmarshalOne, the models
+// are returned along with an error only when it is UnmarshalErrors, so that
+// callers can inspect whatever could be decoded; on any other error nil is
+// returned.
+func UnmarshalAny(in io.Reader, opts ...UnmarshalOption) (interface{}, error) {
+	raw, err := io.ReadAll(in)
+	if err != nil {
+		return nil, err
+	}
+
+	var document struct {
+		Data json.RawMessage `json:"data"`
+	}
+	if err := json.Unmarshal(raw, &document); err != nil {
+		return nil, err
+	}
+
+	if data := bytes.TrimSpace(document.Data); len(data) > 0 && data[0] == '[' {
+		models, err := UnmarshalManyPayload(bytes.NewReader(raw), anyType, opts...)
+		if err != nil {
+			if models == nil {
+				return nil, err
+			}
+			return partial[interface{}](models, err)
+		}
+		return models, nil
+	}
+
+	// Only the type is needed to choose the model; anything else wrong with
+	// "data" is reported by UnmarshalPayload
+	data := new(Node)
+	json.Unmarshal(document.Data, data) //nolint:errcheck
+
+	model, err := newModel(anyType, data, resourceAt("/data"), "")
+	if err != nil {
+		return nil, err
+	}
+
+	if err := UnmarshalPayload(bytes.NewReader(raw), model.Interface(), opts...); err != nil {
+		return partial(model.Interface(), err)
+	}
+	return model.Interface(), nil
+}
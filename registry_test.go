@@ -0,0 +1,186 @@
+package jsonapi
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func init() {
+	RegisterType(&Image{}, &Video{}, &Comment{})
+}
+
+func TestRegisterType_panics(t *testing.T) {
+	for _, tc := range []struct {
+		desc  string
+		model interface{}
+	}{
+		{desc: "notPointer", model: Image{}},
+		{desc: "noPrimary", model: &OneOfMedia{}},
+		{desc: "alreadyRegistered", model: &struct {
+			ID string `jsonapi:"primary,images"`
+		}{}},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			defer func() {
+				if recover() == nil {
+					t.Fatal("Was expecting RegisterType to panic")
+				}
+			}()
+			RegisterType(tc.model)
+		})
+	}
+}
+
+func TestUnmarshalPayload_interfaceRelations(t *testing.T) {
+	payload := `{
+		"data": {
+			"type": "galleries",
+			"id": "1",
+			"relationships": {
+				"cover": {"data": {"type": "images", "id": "i1"}},
+				"items": {"data": [{"type": "videos", "id": "v1"}, {"type": "images", "id": "i1"}]}
+			}
+		},
+		"included": [
+			{"type": "images", "id": "i1", "attributes": {"src": "cover.png"}},
+			{"type": "videos", "id": "v1", "attributes": {"captions": "Hello"}}
+		]
+	}`
+
+	gallery := new(Gallery)
+	if err := UnmarshalPayload(strings.NewReader(payload), gallery); err != nil {
+		t.Fatal(err)
+	}
+
+	cover, ok := gallery.Cover.(*Image)
+	if !ok || cover.Src != "cover.png" {
+		t.Fatalf("Was expecting the cover to be an *Image, got %#v", gallery.Cover)
+	}
+	if len(gallery.Items) != 2 {
+		t.Fatalf("Was expecting 2 items, got %d", len(gallery.Items))
+	}
+	if video, ok := gallery.Items[0].(*Video); !ok || video.Captions != "Hello" {
+		t.Fatalf("Was expecting the first item to be a *Video, got %#v", gallery.Items[0])
+	}
+	if gallery.Items[1] != gallery.Cover {
+		t.Fatal("Was expecting the same image to be assigned to the cover and the items")
+	}
+}
+
+func TestUnmarshalPayload_interfaceRelationsRoundTrip(t *testing.T) {
+	gallery := &Gallery{
+		ID:    "1",
+		Cover: &Video{ID: "v1", Captions: "Hello"},
+		Items: []Media{&Image{ID: "i1", Src: "a.png"}, &Video{ID: "v2"}},
+	}
+
+	out := bytes.NewBuffer(nil)
+	if err := MarshalPayload(out, gallery); err != nil {
+		t.Fatal(err)
+	}
+
+	decoded := new(Gallery)
+	if err := UnmarshalPayload(out, decoded); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(decoded, gallery) {
+		t.Fatalf("Was expecting the gallery to round trip, got %#v", decoded)
+	}
+}
+
+func TestUnmarshalPayload_interfaceRelationErrors(t *testing.T) {
+	for _, tc := range []struct {
+		desc    string
+		payload string
+		pointer string
+	}{
+		{
+			desc:    "unregistered",
+			payload: `{"data": {"type": "galleries", "id": "1", "relationships": {"cover": {"data": {"type": "sounds", "id": "s1"}}}}}`,
+			pointer: "/data/relationships/cover/data/type",
+		},
+		{
+			desc:    "notImplementing",
+			payload: `{"data": {"type": "galleries", "id": "1", "relationships": {"items": {"data": [{"type": "images", "id": "i1"}, {"type": "comments", "id": "1"}]}}}}`,
+			pointer: "/data/relationships/items/data/1/type",
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			err := UnmarshalPayload(strings.NewReader(tc.payload), new(Gallery))
+			if !errors.Is(err, ErrUnknownType) {
+				t.Fatalf("Was expecting %v, got %v", ErrUnknownType, err)
+			}
+			assertUnmarshalErrorPointer(t, err, tc.pointer)
+		})
+	}
+}
+
+func TestUnmarshalManyPayload_interface(t *testing.T) {
+	payload := `{"data": [
+		{"type": "images", "id": "i1", "attributes": {"src": "a.png"}},
+		{"type": "videos", "id": "v1", "attributes": {"captions": "Hello"}}
+	]}`
+
+	models, err := UnmarshalManyPayload(strings.NewReader(payload), reflect.TypeOf((*Media)(nil)).Elem())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []interface{}{&Image{ID: "i1", Src: "a.png"}, &Video{ID: "v1", Captions: "Hello"}}
+	if !reflect.DeepEqual(models, expected) {
+		t.Fatalf("Was expecting %#v, got %#v", expected, models)
+	}
+}
+
+func TestUnmarshalAny(t *testing.T) {
+	model, err := UnmarshalAny(strings.NewReader(`{"data": {"type": "videos", "id": "v1", "attributes": {"captions": "Hello"}}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if video, ok := model.(*Video); !ok || video.Captions != "Hello" {
+		t.Fatalf("Was expecting a *Video, got %#v", model)
+	}
+
+	model, err = UnmarshalAny(strings.NewReader(`{"data": [{"type": "comments", "id": "1"}, {"type": "images", "id": "i1"}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []interface{}{&Comment{ID: 1}, &Image{ID: "i1"}}
+	if !reflect.DeepEqual(model, expected) {
+		t.Fatalf("Was expecting %#v, got %#v", expected, model)
+	}
+
+	_, err = UnmarshalAny(strings.NewReader(`{"data": {"type": "sounds", "id": "s1"}}`))
+	if !errors.Is(err, ErrUnknownType) {
+		t.Fatalf("Was expecting %v, got %v", ErrUnknownType, err)
+	}
+	assertUnmarshalErrorPointer(t, err, "/data/type")
+}
+
+func TestUnmarshalAny_partial(t *testing.T) {
+	for _, payload := range []string{
+		`{"data": {"type": "comments", "id": "c1"}}`,
+		`{"data": [{"type": "videos", "id": "v1"}, {"type": "comments", "id": "c1"}]}`,
+	} {
+		model, err := UnmarshalAny(strings.NewReader(payload))
+		if !errors.Is(err, ErrBadJSONAPIID) {
+			t.Fatalf("Was expecting %v, got %v", ErrBadJSONAPIID, err)
+		}
+		if model != nil {
+			t.Fatalf("Was expecting no model along with a fatal error, got %#v", model)
+		}
+	}
+
+	payload := `{"data": {"type": "videos", "id": "v1", "attributes": {"captions": 1}}}`
+	model, err := UnmarshalAny(strings.NewReader(payload), CollectAllErrors())
+	var errs UnmarshalErrors
+	if !errors.As(err, &errs) {
+		t.Fatalf("Was expecting UnmarshalErrors, got %v", err)
+	}
+	if video, ok := model.(*Video); !ok || video.ID != "v1" {
+		t.Fatalf("Was expecting the partially decoded *Video, got %#v", model)
+	}
+}
@@ -0,0 +1,222 @@
+package jsonapi
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+)
+
+var (
+	// ErrUnknownRelationship is returned by MarshalRelationship and
+	// UnmarshalRelationship when the model has no `relation` or
+	// `polyrelation` field of the given name.
+	ErrUnknownRelationship = errors.New("unknown relationship")
+	// ErrInvalidLinkage is returned by UnmarshalRelationship when "data" is
+	// missing from the document, or does not hold a single resource
+	// identifier (or null) for a to-one relationship, or an array of them for
+	// a to-many relationship.
+	ErrInvalidLinkage = errors.New("invalid resource linkage")
+)
+
+// MarshalRelationship writes the relationship object for the named
+// relationship of model, as served by a relationship endpoint such as
+// /posts/1/relationships/comments. Its "data" holds the resource linkage of
+// the related models, which are not themselves encoded, along with the
+// relationship's links and meta if model implements RelationshipLinkable or
+// RelationshipMetable.
+//
+//	func ShowPostComments(w http.ResponseWriter, r *http.Request) {
+//		post := loadPost(r)
+//
+//		w.Header().Set("Content-Type", jsonapi.MediaType)
+//		if err := jsonapi.MarshalRelationship(w, post, "comments"); err != nil {
+//			http.Error(w, err.Error(), http.StatusInternalServerError)
+//		}
+//	}
+//
+// model interface{} should be a pointer to a struct.
+func MarshalRelationship(w io.Writer, model interface{}, relation string) error {
+	value := reflect.ValueOf(model)
+	if value.Kind() != reflect.Ptr || value.Elem().Kind() != reflect.Struct {
+		return ErrUnexpectedType
+	}
+
+	field, args, err := relationField(value.Elem().Type(), relation)
+	if err != nil {
+		return err
+	}
+
+	fieldValue, ok := fieldByIndex(value.Elem(), field.Index, false)
+	if !ok {
+		fieldValue = reflect.Zero(field.Type)
+	}
+
+	// Related models are encoded as linkage only, and the relationship is
+	// written even when empty
+	e := newEncoder(true, []MarshalOption{MaxRelationshipDepth(0)})
+	node := new(Node)
+	if err := e.visitModelNodeRelation(model, args[0], args[:2], node, fieldValue); err != nil {
+		return err
+	}
+
+	relationship, ok := node.Relationships[relation]
+	if !ok {
+		relationship = &RelationshipOneNode{}
+	}
+	return json.NewEncoder(w).Encode(relationship)
+}
+
+// UnmarshalRelationship decodes a document holding the resource linkage of
+// the named relationship of model, as sent to a relationship endpoint by a
+// PATCH, POST or DELETE request, into that relationship's field. The related
+// models it is set to only have their ID set. For a to-many relationship the
+// field is replaced by the models listed in the document, which are the
+// whole relationship for a PATCH and the members to add or remove for a POST
+// or DELETE.
+//
+//	func AddPostComments(w http.ResponseWriter, r *http.Request) {
+//		post := new(Post)
+//		if err := jsonapi.UnmarshalRelationship(r.Body, post, "comments"); err != nil {
+//			http.Error(w, err.Error(), http.StatusBadRequest)
+//			return
+//		}
+//
+//		// ...add post.Comments to the post identified by the request URL...
+//	}
+//
+// A document whose "data" does not match the cardinality of the relationship
+// is reported as an *UnmarshalError wrapping ErrInvalidLinkage.
+//
+// model interface{} should be a pointer to a struct.
+func UnmarshalRelationship(in io.Reader, model interface{}, relation string, opts ...UnmarshalOption) error {
+	value := reflect.ValueOf(model)
+	if value.Kind() != reflect.Ptr || value.Elem().Kind() != reflect.Struct {
+		return ErrUnexpectedType
+	}
+
+	if _, _, err := relationField(value.Elem().Type(), relation); err != nil {
+		return err
+	}
+
+	d := newDecoder(opts)
+
+	document := map[string]interface{}{}
+	if err := d.decode(in, &document); err != nil {
+		return err
+	}
+
+	if err := d.index(nil); err != nil {
+		return err
+	}
+	if err := d.unmarshalLinkage(document, "", value, relation); err != nil {
+		return err
+	}
+	return d.err()
+}
+
+// unmarshalLinkage decodes the resource linkage of the relationship object
+// relationship, found at pointer, into the named relationship of the struct
+// model points to.
+func (d *decoder) unmarshalLinkage(relationship map[string]interface{}, pointer string, model reflect.Value, relation string) error {
+	field, args, err := relationField(model.Elem().Type(), relation)
+	if err != nil {
+		return err
+	}
+
+	if err := checkLinkage(relationship, pointer, field); err != nil {
+		return d.report(err)
+	}
+	fieldValue, ok := fieldByIndex(model.Elem(), field.Index, true)
+	if !ok {
+		return nil
+	}
+	return d.unmarshalRelation(relationship, pointer, fieldValue, field, args, nil)
+}
+
+// relationField returns the `relation` or `polyrelation` field of the struct
+// type t for the named relationship, along with its tag arguments. As when
+// decoding resources, a polyrelation field is preferred over a relation field
+// of the same name.
+func relationField(t reflect.Type, relation string) (reflect.StructField, []string, error) {
+	var found *reflect.StructField
+	var foundArgs []string
+
+	fields := modelFields(t)
+	for i := range fields {
+		field := fields[i]
+
+		args, err := getStructTags(field)
+		if err != nil {
+			return reflect.StructField{}, nil, err
+		}
+		if len(args) < 2 || args[1] != relation {
+			continue
+		}
+
+		switch args[0] {
+		case annotationPolyRelation:
+			return field, args, nil
+		case annotationRelation:
+			if found == nil {
+				found, foundArgs = &field, args
+			}
+		}
+	}
+
+	if found == nil {
+		return reflect.StructField{}, nil, fmt.Errorf("%w %q on %s", ErrUnknownRelationship, relation, t)
+	}
+	return *found, foundArgs, nil
+}
+
+// checkLinkage verifies that the "data" member of the relationship object
+// found at pointer holds linkage of the cardinality of field. The cardinality
+// of a NullableRelationship is that of its element type, and it may also be
+// set to null when to-many.
+func checkLinkage(relationship map[string]interface{}, pointer string, field reflect.StructField) error {
+	data, ok := relationship["data"]
+	if !ok {
+		return &UnmarshalError{
+			Pointer: pointer,
+			Field:   field.Name,
+			Status:  http.StatusBadRequest,
+			Err:     fmt.Errorf("%w: missing \"data\"", ErrInvalidLinkage),
+		}
+	}
+
+	fieldType := field.Type
+	nullable := strings.HasPrefix(fieldType.Name(), "NullableRelationship[")
+	if nullable {
+		fieldType = fieldType.Elem()
+	}
+
+	var valid bool
+	var want string
+	switch {
+	case fieldType.Kind() == reflect.Slice && nullable:
+		_, isArray := data.([]interface{})
+		valid = isArray || data == nil
+		want = "an array of resource identifiers or null"
+	case fieldType.Kind() == reflect.Slice:
+		_, valid = data.([]interface{})
+		want = "an array of resource identifiers"
+	default:
+		_, isObject := data.(map[string]interface{})
+		valid = isObject || data == nil
+		want = "a resource identifier or null"
+	}
+
+	if valid {
+		return nil
+	}
+	return &UnmarshalError{
+		Pointer: jsonPointer(pointer, "data"),
+		Field:   field.Name,
+		Status:  http.StatusBadRequest,
+		Err:     fmt.Errorf("%w: expected %s", ErrInvalidLinkage, want),
+	}
+}
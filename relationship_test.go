@@ -0,0 +1,185 @@
+package jsonapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestMarshalRelationship_toMany(t *testing.T) {
+	blog := testBlog()
+
+	out := bytes.NewBuffer(nil)
+	if err := MarshalRelationship(out, blog, "posts"); err != nil {
+		t.Fatal(err)
+	}
+
+	var relationship map[string]interface{}
+	if err := json.Unmarshal(out.Bytes(), &relationship); err != nil {
+		t.Fatal(err)
+	}
+
+	data, ok := relationship["data"].([]interface{})
+	if !ok || len(data) != 2 {
+		t.Fatalf("Was expecting the linkage of 2 posts, got %v", relationship["data"])
+	}
+	for i, id := range []string{"1", "2"} {
+		identifier := data[i].(map[string]interface{})
+		if identifier["type"] != "posts" || identifier["id"] != id || identifier["attributes"] != nil {
+			t.Fatalf("Was expecting a resource identifier for post %s, got %v", id, identifier)
+		}
+	}
+
+	links := relationship["links"].(map[string]interface{})
+	if related := links["related"].(map[string]interface{}); related["href"] != "https://example.com/api/blogs/5/posts" {
+		t.Fatalf("Was expecting the related link, got %v", links)
+	}
+	if relationship["meta"] == nil {
+		t.Fatal("Was expecting the relationship meta")
+	}
+}
+
+func TestMarshalRelationship_toOne(t *testing.T) {
+	for _, tc := range []struct {
+		desc     string
+		post     *Post
+		expected string
+	}{
+		{
+			desc:     "set",
+			post:     &Post{ID: 1, LatestComment: &Comment{ID: 3, Body: "Hi"}},
+			expected: `{"data":{"type":"comments","id":"3"}}`,
+		},
+		{
+			desc:     "null",
+			post:     &Post{ID: 1},
+			expected: `{"data":null}`,
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			out := bytes.NewBuffer(nil)
+			if err := MarshalRelationship(out, tc.post, "latest_comment"); err != nil {
+				t.Fatal(err)
+			}
+			if got := strings.TrimSpace(out.String()); got != tc.expected {
+				t.Fatalf("Was expecting %s, got %s", tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestMarshalRelationship_unknown(t *testing.T) {
+	err := MarshalRelationship(bytes.NewBuffer(nil), &Post{}, "authors")
+	if !errors.Is(err, ErrUnknownRelationship) {
+		t.Fatalf("Was expecting %v, got %v", ErrUnknownRelationship, err)
+	}
+}
+
+func TestUnmarshalRelationship(t *testing.T) {
+	post := new(Post)
+	in := strings.NewReader(`{"data": [{"type": "comments", "id": "1"}, {"type": "comments", "id": "2"}]}`)
+	if err := UnmarshalRelationship(in, post, "comments"); err != nil {
+		t.Fatal(err)
+	}
+	if len(post.Comments) != 2 || post.Comments[0].ID != 1 || post.Comments[1].ID != 2 {
+		t.Fatalf("Was expecting ID-only comments, got %+v", post.Comments)
+	}
+
+	in = strings.NewReader(`{"data": {"type": "comments", "id": "3"}}`)
+	if err := UnmarshalRelationship(in, post, "latest_comment"); err != nil {
+		t.Fatal(err)
+	}
+	if post.LatestComment == nil || post.LatestComment.ID != 3 {
+		t.Fatalf("Was expecting an ID-only comment, got %+v", post.LatestComment)
+	}
+}
+
+func TestUnmarshalRelationship_nullableToMany(t *testing.T) {
+	model := new(WithNullableRelationships)
+	in := strings.NewReader(`{"data": [{"type": "comments", "id": "1"}]}`)
+	if err := UnmarshalRelationship(in, model, "comments"); err != nil {
+		t.Fatal(err)
+	}
+	comments, err := model.Comments.Get()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(comments) != 1 || comments[0].ID != 1 {
+		t.Fatalf("Was expecting an ID-only comment, got %+v", comments)
+	}
+
+	in = strings.NewReader(`{"data": null}`)
+	if err := UnmarshalRelationship(in, model, "comments"); err != nil {
+		t.Fatal(err)
+	}
+	if !model.Comments.IsNull() {
+		t.Fatalf("Was expecting the comments to be null, got %+v", model.Comments)
+	}
+
+	in = strings.NewReader(`{"data": {"type": "comments", "id": "1"}}`)
+	if err := UnmarshalRelationship(in, model, "comments"); !errors.Is(err, ErrInvalidLinkage) {
+		t.Fatalf("Was expecting ErrInvalidLinkage, got %v", err)
+	}
+}
+
+func TestUnmarshalRelationship_roundTrip(t *testing.T) {
+	out := bytes.NewBuffer(nil)
+	if err := MarshalRelationship(out, testBlog(), "posts"); err != nil {
+		t.Fatal(err)
+	}
+
+	blog := new(Blog)
+	if err := UnmarshalRelationship(out, blog, "posts"); err != nil {
+		t.Fatal(err)
+	}
+	if len(blog.Posts) != 2 || blog.Posts[0].ID != 1 || blog.Posts[1].ID != 2 {
+		t.Fatalf("Was expecting the posts to round trip, got %+v", blog.Posts)
+	}
+}
+
+func TestUnmarshalRelationship_invalid(t *testing.T) {
+	for _, tc := range []struct {
+		desc     string
+		relation string
+		payload  string
+		expected error
+		pointer  string
+	}{
+		{
+			desc:     "missingData",
+			relation: "comments",
+			payload:  `{"meta": {}}`,
+			expected: ErrInvalidLinkage,
+		},
+		{
+			desc:     "toManyGivenObject",
+			relation: "comments",
+			payload:  `{"data": {"type": "comments", "id": "1"}}`,
+			expected: ErrInvalidLinkage,
+			pointer:  "/data",
+		},
+		{
+			desc:     "toOneGivenArray",
+			relation: "latest_comment",
+			payload:  `{"data": []}`,
+			expected: ErrInvalidLinkage,
+			pointer:  "/data",
+		},
+		{
+			desc:     "wrongType",
+			relation: "comments",
+			payload:  `{"data": [{"type": "comments", "id": "1"}, {"type": "posts", "id": "2"}]}`,
+			pointer:  "/data/1/type",
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			err := UnmarshalRelationship(strings.NewReader(tc.payload), new(Post), tc.relation)
+			if tc.expected != nil && !errors.Is(err, tc.expected) {
+				t.Fatalf("Was expecting %v, got %v", tc.expected, err)
+			}
+			assertUnmarshalErrorPointer(t, err, tc.pointer)
+		})
+	}
+}
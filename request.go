@@ -2,10 +2,13 @@ package jsonapi
 
 import (
 	"bytes"
+	"encoding"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
+	"math"
+	"net/http"
 	"reflect"
 	"strconv"
 	"strings"
@@ -26,14 +29,37 @@ var (
 	// ErrInvalidRFC3339 is returned when a struct has a time.Time type field and includes
 	// "rfc3339" in the tag spec, but the JSON value was not an RFC3339 timestamp string.
 	ErrInvalidRFC3339 = errors.New("Only strings can be parsed as dates, RFC3339 timestamps")
+	// ErrInvalidTimeLayout is returned when a struct has a time.Time type field
+	// and includes "date" or "layout=" in the tag spec, but the JSON value was not
+	// a string in that layout.
+	ErrInvalidTimeLayout = errors.New("Only strings can be parsed as dates, in the layout of the tag")
 	// ErrUnknownFieldNumberType is returned when the JSON value was a float
 	// (numeric) but the Struct field was a non numeric type (i.e. not int, uint,
 	// float, etc)
 	ErrUnknownFieldNumberType = errors.New("The struct field was not of a known number type")
+	// ErrNumberOutOfRange is returned when the JSON value was a number that the
+	// struct field's numeric type cannot represent, because it is too large or
+	// has a fraction.
+	ErrNumberOutOfRange = errors.New("The number does not fit the struct field's type")
 	// ErrInvalidType is returned when the given type is incompatible with the expected type.
 	ErrInvalidType = errors.New("Invalid type provided") // I wish we used punctuation.
 	// ErrTypeNotFound is returned when the given type not found on the model.
 	ErrTypeNotFound = errors.New("no primary type annotation found on model")
+	// ErrUnknownMember is returned in DisallowUnknownMembers mode when the
+	// request document contains a member that the model or the spec does not
+	// define.
+	ErrUnknownMember = errors.New("unknown member")
+	// ErrUnknownType is returned in DisallowUnknownMembers mode when a
+	// polymorphic relationship refers to a type the choice struct has no field
+	// for.
+	ErrUnknownType = errors.New("unknown resource type")
+	// ErrUndefinedLid is returned by the *WithLidMap functions when a
+	// relationship refers to a local ID that no resource in the request
+	// document defines.
+	ErrUndefinedLid = errors.New("undefined lid")
+	// ErrDuplicateLid is returned by the *WithLidMap functions when more than
+	// one resource in the request document defines the same local ID.
+	ErrDuplicateLid = errors.New("duplicate lid")
 )
 
 // ErrUnsupportedPtrType is returned when the Struct field was a pointer but
@@ -91,89 +117,370 @@ func newErrUnsupportedPtrType(rf reflect.Value, t reflect.Type, structField refl
 //
 // Visit https://github.com/google/jsonapi#create for more info.
 //
+// Problems with the contents of the request document are reported as an
+// *UnmarshalError carrying a JSON Pointer to the offending member, which can
+// be converted with its ErrorObject method and passed to MarshalErrors.
+//
 // model interface{} should be a pointer to a struct.
-func UnmarshalPayload(in io.Reader, model interface{}) error {
+func UnmarshalPayload(in io.Reader, model interface{}, opts ...UnmarshalOption) error {
+	_, err := UnmarshalDocument(in, model, opts...)
+	return err
+}
+
+// UnmarshalDocument works like UnmarshalPayload, and also returns the
+// top-level links, meta and jsonapi members of the document along with its
+// included resources. The Document is returned whenever the document could be
+// read, even if model could not be fully decoded from it.
+//
+//	doc, err := jsonapi.UnmarshalDocument(resp.Body, blog)
+//	if err != nil {
+//		return err
+//	}
+//	next := (*doc.Links)["next"]
+func UnmarshalDocument(in io.Reader, model interface{}, opts ...UnmarshalOption) (*Document, error) {
 	payload := new(OnePayload)
+	d := newDecoder(opts)
 
-	if err := json.NewDecoder(in).Decode(payload); err != nil {
-		return err
+	if err := d.decode(in, payload); err != nil {
+		return nil, err
 	}
+	doc := newDocument(payload.Links, payload.Meta, payload.JSONAPI, payload.Included)
 
-	if payload.Included != nil {
-		includedMap := make(map[string]*Node)
-		for _, included := range payload.Included {
-			if included.Lid != "" {
-				included.ID = included.Lid
-			}
-			key := fmt.Sprintf("%s,%s", included.Type, included.ID)
-			includedMap[key] = included
-		}
-
-		return unmarshalNode(payload.Data, reflect.ValueOf(model), &includedMap)
+	if err := d.index(payload.Included); err != nil {
+		return doc, err
 	}
-	return unmarshalNode(payload.Data, reflect.ValueOf(model), nil)
+	if err := d.unmarshalPrimary(payload.Data, reflect.ValueOf(model), resourceAt("/data")); err != nil {
+		return doc, err
+	}
+	return doc, d.err()
 }
 
-func UnmarshalPayloadWithLidMap(in io.Reader, model interface{}, generator IDGenerator) (map[string]string, error) {
+// UnmarshalPayloadWithLidMap works like UnmarshalPayload for documents that
+// create resources identified by a local ID ("lid") rather than an "id". Each
+// such resource, in "data" or "included", is given an ID from generator, and
+// relationships that refer to it by its lid are decoded with that ID,
+// whichever order the resources appear in. The lids are returned mapped to
+// the generated IDs.
+//
+// A lid that is referred to but never defined, or defined by more than one
+// resource, is reported as an *UnmarshalError wrapping ErrUndefinedLid or
+// ErrDuplicateLid.
+func UnmarshalPayloadWithLidMap(in io.Reader, model interface{}, generator IDGenerator, opts ...UnmarshalOption) (map[string]string, error) {
+	if generator == nil {
+		return nil, errors.New(notNilGeneratorError)
+	}
+
 	payload := new(OnePayload)
+	d := newDecoder(opts)
+	d.lids = &lidResolver{generator: generator, ids: LidMap{}}
 
-	if err := json.NewDecoder(in).Decode(payload); err != nil {
+	if err := d.decode(in, payload); err != nil {
 		return nil, err
 	}
 
-	lidMap := LidMap{}
-
-	if payload.Included != nil {
-		includedMap := make(map[string]*Node)
-		for _, included := range payload.Included {
-			if included.Lid != "" {
-				newId, _ := generator.Generate()
-				lidMap[included.Lid] = newId
-				included.ID = newId
-			}
-			key := fmt.Sprintf("%s,%s", included.Type, included.ID)
-			includedMap[key] = included
-		}
-
-		return lidMap, unmarshalNodeWithLidMap(payload.Data, reflect.ValueOf(model), &includedMap, generator, lidMap)
+	if err := d.defineLid(payload.Data, "/data"); err != nil {
+		return d.lids.ids, err
+	}
+	if err := d.index(payload.Included); err != nil {
+		return d.lids.ids, err
 	}
-	return lidMap, unmarshalNodeWithLidMap(payload.Data, reflect.ValueOf(model), nil, generator, lidMap)
+	if err := d.unmarshalPrimary(payload.Data, reflect.ValueOf(model), resourceAt("/data")); err != nil {
+		return d.lids.ids, err
+	}
+	return d.lids.ids, d.err()
 }
 
 // UnmarshalManyPayload converts an io into a set of struct instances using
 // jsonapi tags on the type's struct fields.
-func UnmarshalManyPayload(in io.Reader, t reflect.Type) ([]interface{}, error) {
+//
+// In CollectAllErrors mode the models are returned alongside the errors, so
+// that callers can inspect whatever could be decoded.
+func UnmarshalManyPayload(in io.Reader, t reflect.Type, opts ...UnmarshalOption) ([]interface{}, error) {
+	models, _, err := UnmarshalManyDocument(in, t, opts...)
+	return models, err
+}
+
+// UnmarshalManyDocument works like UnmarshalManyPayload, and also returns the
+// top-level members of the document as UnmarshalDocument does, such as the
+// pagination links and total counts of a list response.
+func UnmarshalManyDocument(in io.Reader, t reflect.Type, opts ...UnmarshalOption) ([]interface{}, *Document, error) {
 	payload := new(ManyPayload)
+	d := newDecoder(opts)
 
-	if err := json.NewDecoder(in).Decode(payload); err != nil {
-		return nil, err
+	if err := d.decode(in, payload); err != nil {
+		return nil, nil, err
 	}
+	doc := newDocument(payload.Links, payload.Meta, payload.JSONAPI, payload.Included)
 
-	models := []interface{}{}         // will be populated from the "data"
-	includedMap := map[string]*Node{} // will be populate from the "included"
+	if err := d.index(payload.Included); err != nil {
+		return nil, doc, err
+	}
+	models, err := d.unmarshalMany(payload.Data, t)
+	return models, doc, err
+}
 
-	if payload.Included != nil {
-		for _, included := range payload.Included {
-			if included.Lid != "" {
-				included.ID = included.Lid
-			}
-			key := fmt.Sprintf("%s,%s", included.Type, included.ID)
-			includedMap[key] = included
+// newDocument returns the Document for the top-level members of a decoded
+// payload.
+func newDocument(links *Links, meta *Meta, jsonapi *JSONAPIObject, included []*Node) *Document {
+	doc := &Document{
+		Links:    unmarshalLinks(links),
+		Meta:     unmarshalMeta(meta),
+		JSONAPI:  jsonapi,
+		Included: included,
+	}
+	if jsonapi != nil {
+		jsonapi.Meta = unmarshalMeta(jsonapi.Meta)
+	}
+	return doc
+}
+
+// UnmarshalManyPayloadWithLidMap works like UnmarshalManyPayload for bulk
+// creates of resources identified by a local ID ("lid"), resolving lids as
+// UnmarshalPayloadWithLidMap does. One set of lids is shared by every resource
+// in "data" and "included", so resources may refer to each other by lid in
+// any order.
+func UnmarshalManyPayloadWithLidMap(in io.Reader, t reflect.Type, generator IDGenerator, opts ...UnmarshalOption) ([]interface{}, map[string]string, error) {
+	if generator == nil {
+		return nil, nil, errors.New(notNilGeneratorError)
+	}
+
+	payload := new(ManyPayload)
+	d := newDecoder(opts)
+	d.lids = &lidResolver{generator: generator, ids: LidMap{}}
+
+	if err := d.decode(in, payload); err != nil {
+		return nil, nil, err
+	}
+
+	for i, data := range payload.Data {
+		if err := d.defineLid(data, jsonPointer("/data", strconv.Itoa(i))); err != nil {
+			return nil, d.lids.ids, err
 		}
 	}
+	if err := d.index(payload.Included); err != nil {
+		return nil, d.lids.ids, err
+	}
+
+	models, err := d.unmarshalMany(payload.Data, t)
+	return models, d.lids.ids, err
+}
+
+// unmarshalMany decodes each resource of a many payload into a new instance
+// of the struct type t points to, or for an interface type t, of the model
+// registered for the type of the resource.
+func (d *decoder) unmarshalMany(data []*Node, t reflect.Type) ([]interface{}, error) {
+	models := []interface{}{} // will be populated from the "data"
+
+	for i, n := range data {
+		loc := resourceAt(jsonPointer("/data", strconv.Itoa(i)))
+		model, err := newModel(t, n, loc, "")
+		if err != nil {
+			if err := d.report(err); err != nil {
+				return nil, err
+			}
+			continue
+		}
 
-	for _, data := range payload.Data {
-		model := reflect.New(t.Elem())
-		err := unmarshalNode(data, model, &includedMap)
+		err = d.unmarshalPrimary(n, model, loc)
 		if err != nil {
 			return nil, err
 		}
 		models = append(models, model.Interface())
 	}
 
+	if err := d.err(); err != nil {
+		return models, err
+	}
+
 	return models, nil
 }
 
+// decoder holds the state shared by every node decoded from a single request
+// document.
+type decoder struct {
+	// included maps the "type,id" key of each sideloaded resource to its node.
+	included map[string]*Node
+	// includedIndex maps the same keys to the position of the resource in the
+	// "included" array, so that errors can point at it.
+	includedIndex map[string]int
+
+	// lids resolves local IDs to generated IDs for the *WithLidMap functions,
+	// and is nil otherwise.
+	lids *lidResolver
+
+	// visited maps the "type,id" key of each resource decoded so far to the
+	// model it was decoded into, so that a resource reached again through a
+	// cycle of relationships is assigned that model instead of being decoded
+	// over and over.
+	visited map[string]reflect.Value
+	// depth counts the included resources between the primary data and the
+	// resource being decoded.
+	depth int
+
+	// patch is set by ApplyPatch, which decodes into a model already holding
+	// the resource: members sent as null clear their fields, and relationship
+	// objects without "data" leave theirs as they are.
+	patch bool
+
+	opts unmarshalOptions
+	// errs accumulates the errors reported in CollectAllErrors mode.
+	errs UnmarshalErrors
+}
+
+func newDecoder(opts []UnmarshalOption) *decoder {
+	d := &decoder{}
+	for _, opt := range opts {
+		opt(&d.opts)
+	}
+	return d
+}
+
+// newNumberDecoder returns a json.Decoder that decodes numbers into
+// json.Number, so that they can be converted to the field they are decoded
+// into without first losing precision as a float64.
+func newNumberDecoder(in io.Reader) *json.Decoder {
+	dec := json.NewDecoder(in)
+	dec.UseNumber()
+	return dec
+}
+
+// decode reads the request document into payload. In DisallowUnknownMembers
+// mode the raw document is checked for top-level and resource object members
+// that the JSON API spec does not define.
+func (d *decoder) decode(in io.Reader, payload interface{}) error {
+	if !d.opts.disallowUnknownMembers {
+		return newNumberDecoder(in).Decode(payload)
+	}
+
+	raw, err := io.ReadAll(in)
+	if err != nil {
+		return err
+	}
+
+	if err := newNumberDecoder(bytes.NewReader(raw)).Decode(payload); err != nil {
+		return err
+	}
+
+	var document map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &document); err != nil {
+		return err
+	}
+
+	return d.checkDocumentMembers(document)
+}
+
+// index records the sideloaded resources so that relationship linkage can be
+// resolved to them. Resources identified by a local ID are keyed by it, or
+// by the ID generated for it when resolving lids.
+func (d *decoder) index(included []*Node) error {
+	d.included = make(map[string]*Node, len(included))
+	d.includedIndex = make(map[string]int, len(included))
+
+	for i, n := range included {
+		if d.lids != nil {
+			if err := d.defineLid(n, jsonPointer("/included", strconv.Itoa(i))); err != nil {
+				return err
+			}
+		} else if n.Lid != "" {
+			n.ID = n.Lid
+		}
+
+		key := resourceKey(n)
+		d.included[key] = n
+		d.includedIndex[key] = i
+	}
+
+	return nil
+}
+
+// report handles an error raised while decoding. In CollectAllErrors mode
+// errors located in the request document are recorded and nil is returned so
+// that decoding can carry on; any other error is returned to stop decoding.
+func (d *decoder) report(err error) error {
+	if !d.opts.collectErrors {
+		return err
+	}
+
+	switch e := err.(type) {
+	case *UnmarshalError:
+		d.errs = append(d.errs, e)
+	case UnmarshalErrors:
+		d.errs = append(d.errs, e...)
+	default:
+		return err
+	}
+
+	return nil
+}
+
+// err returns the errors recorded in CollectAllErrors mode, if any.
+func (d *decoder) err() error {
+	if len(d.errs) == 0 {
+		return nil
+	}
+	return d.errs
+}
+
+// location identifies where a node being decoded was read from in the request
+// document, so that errors can carry a JSON Pointer to the offending member.
+type location struct {
+	// node points at the resource object itself.
+	node string
+	// attributes points at the object holding the node's attribute members.
+	// For nested struct attributes this is the attribute value itself.
+	attributes string
+}
+
+// resourceAt returns the location of a resource object found at pointer.
+func resourceAt(pointer string) location {
+	return location{node: pointer, attributes: pointer + "/attributes"}
+}
+
+var pointerTokenEscaper = strings.NewReplacer("~", "~0", "/", "~1")
+
+// jsonPointer appends the reference tokens to the JSON Pointer base, escaping
+// them as described in RFC 6901.
+func jsonPointer(base string, tokens ...string) string {
+	var b strings.Builder
+	b.WriteString(base)
+	for _, token := range tokens {
+		b.WriteByte('/')
+		b.WriteString(pointerTokenEscaper.Replace(token))
+	}
+	return b.String()
+}
+
+// newUnmarshalError locates err at pointer within the request document.
+// Errors that already carry a location, such as those raised while decoding a
+// nested struct attribute, are returned unchanged.
+func newUnmarshalError(err error, pointer, field string) error {
+	if _, ok := err.(*UnmarshalError); ok {
+		return err
+	}
+	return &UnmarshalError{
+		Pointer: pointer,
+		Field:   field,
+		Status:  http.StatusBadRequest,
+		Err:     err,
+	}
+}
+
+// newTypeMismatchError reports a resource whose type does not match the
+// `primary` annotation of the model it is decoded into. The spec mandates a
+// 409 Conflict for this case.
+func newTypeMismatchError(got, want, pointer, field string) error {
+	return &UnmarshalError{
+		Pointer: pointer,
+		Field:   field,
+		Status:  http.StatusConflict,
+		Err: fmt.Errorf(
+			"Trying to Unmarshal an object of type %#v, but %#v does not match",
+			got,
+			want,
+		),
+	}
+}
+
 // jsonapiTypeOfModel returns a jsonapi primary type string
 // given a struct type that has typical jsonapi struct tags
 //
@@ -186,8 +493,7 @@ func UnmarshalManyPayload(in io.Reader, t reflect.Type) ([]interface{}, error) {
 //	    ID string `jsonapi:"primary,posts"`
 //	}
 func jsonapiTypeOfModel(structModel reflect.Type) (string, error) {
-	for i := 0; i < structModel.NumField(); i++ {
-		fieldType := structModel.Field(i)
+	for _, fieldType := range modelFields(structModel) {
 		args, err := getStructTags(fieldType)
 
 		// A jsonapi tag was found, but it was improperly structured
@@ -290,12 +596,18 @@ func getStructTags(field reflect.StructField) ([]string, error) {
 		return nil, ErrBadJSONAPIStructTag
 	}
 
+	if annotation == annotationAttribute {
+		if err := checkTimeLayout(args); err != nil {
+			return nil, err
+		}
+	}
+
 	return args, nil
 }
 
 // unmarshalNodeMaybeChoice populates a model that may or may not be
 // a choice type struct that corresponds to a polyrelation or relation
-func unmarshalNodeMaybeChoice(m *reflect.Value, data *Node, annotation string, choiceTypeMapping map[string]structFieldIndex, included *map[string]*Node) error {
+func (d *decoder) unmarshalNodeMaybeChoice(m *reflect.Value, data *Node, loc location, field, annotation string, choiceTypeMapping map[string]structFieldIndex) error {
 	// This will hold either the value of the choice type model or the actual
 	// model, depending on annotation
 	var actualModel = *m
@@ -308,17 +620,48 @@ func unmarshalNodeMaybeChoice(m *reflect.Value, data *Node, annotation string, c
 			// this shouldn't necessarily be an error because a newer version of
 			// the API could be communicating with an older version of the client
 			// library, in which case all choice variants would be nil.
+			// Strict decoding reports it instead.
+			if d.opts.disallowUnknownMembers {
+				return &UnmarshalError{
+					Pointer: jsonPointer(loc.node, "type"),
+					Field:   field,
+					Status:  http.StatusConflict,
+					Err:     fmt.Errorf("%w %q", ErrUnknownType, data.Type),
+				}
+			}
 			return nil
 		}
 		choiceElem = &c
 		actualModel = reflect.New(choiceElem.Type)
+	} else if actualModel.Type().Elem().Kind() == reflect.Interface {
+		// m points to an interface, to be set to the model registered for
+		// the type of the resource
+		model, err := newModel(actualModel.Type().Elem(), data, loc, field)
+		if err != nil {
+			return err
+		}
+		actualModel = model
+	} else if err := checkLinkageType(data, actualModel, loc, field); err != nil {
+		return err
+	}
+
+	node, nodeLoc, err := d.fullNode(data, loc)
+	if err != nil {
+		return err
 	}
 
-	if err := unmarshalNode(
-		fullNode(data, included),
-		actualModel,
-		included,
-	); err != nil {
+	if model, ok := d.visited[resourceKey(node)]; ok && model.Type() == actualModel.Type() {
+		// The resource has already been decoded, possibly by a caller further
+		// up a cycle of relationships
+		actualModel = model
+	} else if node != data && d.includeDepthLeft() {
+		d.depth++
+		err := d.unmarshalResource(node, actualModel, nodeLoc)
+		d.depth--
+		if err != nil {
+			return err
+		}
+	} else if err := d.unmarshalNode(data, actualModel, loc); err != nil {
 		return err
 	}
 
@@ -328,67 +671,96 @@ func unmarshalNodeMaybeChoice(m *reflect.Value, data *Node, annotation string, c
 		// at choiceElem.FieldNum
 		v := m.Elem()
 		v.Field(choiceElem.FieldNum).Set(actualModel)
+	} else {
+		*m = actualModel
 	}
 	return nil
 }
 
-func unmarshalNodeMaybeChoiceWithLidMap(m *reflect.Value, data *Node, annotation string, choiceTypeMapping map[string]structFieldIndex, included *map[string]*Node, generator IDGenerator, lidMap LidMap) error {
-	// This will hold either the value of the choice type model or the actual
-	// model, depending on annotation
-	var actualModel = *m
-	var choiceElem *structFieldIndex = nil
+// newRelatedModel returns a new value to decode a model related through a
+// `relation` field of type t into: a pointer to the model for a field
+// holding pointers to it, or a pointer to the interface for a field typed as
+// an interface.
+func newRelatedModel(t reflect.Type) reflect.Value {
+	if t.Kind() == reflect.Interface {
+		return reflect.New(t)
+	}
+	return reflect.New(t.Elem())
+}
 
-	if annotation == annotationPolyRelation {
-		c, ok := choiceTypeMapping[data.Type]
-		if !ok {
-			// If there is no valid choice field to assign this type of relation,
-			// this shouldn't necessarily be an error because a newer version of
-			// the API could be communicating with an older version of the client
-			// library, in which case all choice variants would be nil.
-			return nil
+// unmarshalResource decodes the resource object data into model, recording
+// model as the one decoded for the resource so that relationships leading
+// back to it are assigned the same model.
+func (d *decoder) unmarshalResource(data *Node, model reflect.Value, loc location) error {
+	if data != nil && (data.ID != "" || data.Lid != "") {
+		key := resourceKey(data)
+		if d.visited == nil {
+			d.visited = map[string]reflect.Value{}
+		}
+		if _, ok := d.visited[key]; !ok {
+			d.visited[key] = model
 		}
-		choiceElem = &c
-		actualModel = reflect.New(choiceElem.Type)
 	}
 
-	if err := unmarshalNodeWithLidMap(
-		fullNode(data, included),
-		actualModel,
-		included,
-		generator,
-		lidMap,
-	); err != nil {
-		return err
+	return d.unmarshalNode(data, model, loc)
+}
+
+// includeDepthLeft reports whether the relationships of the resource being
+// decoded may still be resolved from "included" under MaxIncludeDepth.
+func (d *decoder) includeDepthLeft() bool {
+	return !d.opts.limitIncludeDepth || d.depth < d.opts.maxIncludeDepth
+}
+
+// resourceKey returns the "type,id" key identifying the resource n, using
+// its local ID when it has no ID yet.
+func resourceKey(n *Node) string {
+	id := n.ID
+	if id == "" {
+		id = n.Lid
 	}
+	return fmt.Sprintf("%s,%s", n.Type, id)
+}
 
-	if choiceElem != nil {
-		// actualModel is a pointer to the model type
-		// m is a pointer to a struct that should hold the actualModel
-		// at choiceElem.FieldNum
-		v := m.Elem()
-		v.Field(choiceElem.FieldNum).Set(actualModel)
+// checkLinkageType verifies that the type of a resource linkage matches the
+// `primary` annotation of the model it will be decoded into. Checking the
+// linkage rather than the included resource it resolves to points the error
+// at the member the client actually got wrong.
+func checkLinkageType(linkage *Node, model reflect.Value, loc location, field string) error {
+	modelType := model.Type().Elem()
+	if modelType.Kind() != reflect.Struct {
+		return nil
 	}
-	return nil
+
+	t, err := jsonapiTypeOfModel(modelType)
+	if err != nil || t == linkage.Type {
+		return nil
+	}
+
+	return newTypeMismatchError(linkage.Type, t, jsonPointer(loc.node, "type"), field)
 }
 
-func unmarshalNode(data *Node, model reflect.Value, included *map[string]*Node) (err error) {
+func (d *decoder) unmarshalNode(data *Node, model reflect.Value, loc location) (err error) {
 	defer func() {
 		if r := recover(); r != nil {
-			err = fmt.Errorf("data is not a jsonapi representation of '%v'", model.Type())
+			err = d.report(&UnmarshalError{
+				Pointer: loc.node,
+				Status:  http.StatusBadRequest,
+				Err:     fmt.Errorf("data is not a jsonapi representation of '%v'", model.Type()),
+			})
 		}
 	}()
 
 	modelValue := model.Elem()
 	modelType := modelValue.Type()
 	polyrelationFields := map[string]reflect.Type{}
+	knownAttributes := map[string]bool{}
+	knownRelationships := map[string]bool{}
 
 	var er error
 
-	// preprocess the model to find polyrelation fields
-	for i := 0; i < modelValue.NumField(); i++ {
-		fieldValue := modelValue.Field(i)
-		fieldType := modelType.Field(i)
-
+	// preprocess the model to find polyrelation fields and the members it
+	// defines
+	for _, fieldType := range modelFields(modelType) {
 		args, err := getStructTags(fieldType)
 		if err != nil {
 			er = err
@@ -403,14 +775,18 @@ func unmarshalNode(data *Node, model reflect.Value, included *map[string]*Node)
 		name := args[1]
 
 		if annotation == annotationPolyRelation {
-			polyrelationFields[name] = fieldValue.Type()
+			polyrelationFields[name] = fieldType.Type
 		}
-	}
 
-	for i := 0; i < modelValue.NumField(); i++ {
-		fieldValue := modelValue.Field(i)
-		fieldType := modelType.Field(i)
+		switch annotation {
+		case annotationAttribute:
+			knownAttributes[name] = true
+		case annotationRelation, annotationPolyRelation:
+			knownRelationships[name] = true
+		}
+	}
 
+	for _, fieldType := range modelFields(modelType) {
 		args, err := getStructTags(fieldType)
 		if err != nil {
 			er = err
@@ -421,19 +797,26 @@ func unmarshalNode(data *Node, model reflect.Value, included *map[string]*Node)
 		}
 		annotation := args[0]
 
+		// Embedded struct pointers holding promoted fields are only allocated
+		// for the members present in data
+		fieldValue, ok := fieldByIndex(modelValue, fieldType.Index, nodeHasMember(data, args))
+		if !ok {
+			continue
+		}
+
 		if annotation == annotationPrimary {
 			// Check the JSON API Type
 			if data.Type != args[1] {
-				er = fmt.Errorf(
-					"Trying to Unmarshal an object of type %#v, but %#v does not match",
-					data.Type,
-					args[1],
-				)
-				break
+				if er = d.report(newTypeMismatchError(data.Type, args[1], jsonPointer(loc.node, "type"), fieldType.Name)); er != nil {
+					break
+				}
+				continue
 			}
 
 			if data.ID == "" {
-				if data.Lid == "" {
+				// With a lid map, a resource left without an ID has already
+				// been reported as having a duplicate lid
+				if data.Lid == "" || d.lids != nil {
 					continue
 				}
 				data.ID = data.Lid
@@ -457,22 +840,17 @@ func unmarshalNode(data *Node, model reflect.Value, included *map[string]*Node)
 			}
 
 			// Value was not a string... only other supported type was a numeric,
-			// which would have been sent as a float value.
-			floatValue, err := strconv.ParseFloat(data.ID, 64)
-			if err != nil {
-				// Could not convert the value in the "id" attr to a float
-				er = ErrBadJSONAPIID
-				break
-			}
-
-			// Convert the numeric float to one of the supported ID numeric types
-			// (int[8,16,32,64] or uint[8,16,32,64])
-			idValue, err := handleNumeric(floatValue, fieldType.Type, fieldValue)
+			// which is converted to one of the supported ID numeric types
+			// (int[8,16,32,64] or uint[8,16,32,64]) without rounding it through a
+			// float, so that large IDs survive.
+			idValue, err := handleNumeric(json.Number(data.ID), fieldType.Type, fieldValue)
 			if err != nil {
-				// We had a JSON float (numeric), but our field was not one of the
-				// allowed numeric types
-				er = ErrBadJSONAPIID
-				break
+				// The "id" was not a number that fits the field, or our field was
+				// not one of the allowed numeric types
+				if er = d.report(newUnmarshalError(ErrBadJSONAPIID, jsonPointer(loc.node, "id"), fieldType.Name)); er != nil {
+					break
+				}
+				continue
 			}
 
 			assign(fieldValue, idValue)
@@ -496,570 +874,309 @@ func unmarshalNode(data *Node, model reflect.Value, included *map[string]*Node)
 				continue
 			}
 
-			// explicit null for NullableAttr[T] should be preserved
+			// explicit null for NullableAttr[T] and Optional[T] should be preserved
 			if attribute == nil {
 				if strings.HasPrefix(fieldType.Type.Name(), "NullableAttr[") {
 					fieldValue.Set(reflect.MakeMapWithSize(fieldValue.Type(), 1))
 					fieldValue.SetMapIndex(reflect.ValueOf(false), reflect.Zero(fieldValue.Type().Elem()))
-				} else if fieldValue.Kind() == reflect.Ptr {
+				} else if isOptional(fieldType.Type) {
+					optionalOf(fieldValue).SetNull()
+				} else if fieldValue.Kind() == reflect.Ptr || d.patch {
 					fieldValue.Set(reflect.Zero(fieldValue.Type()))
 				}
 				continue
 			}
 
+			attrPointer := jsonPointer(loc.attributes, args[1])
 			structField := fieldType
-			value, err := unmarshalAttribute(attribute, args, structField, fieldValue)
+			value, err := d.unmarshalAttribute(attribute, args, structField, fieldValue, attrPointer)
 			if err != nil {
-				er = err
-				break
+				if er = d.report(newUnmarshalError(err, attrPointer, fieldType.Name)); er != nil {
+					break
+				}
+				continue
 			}
 
 			assign(fieldValue, value)
 		} else if annotation == annotationRelation || annotation == annotationPolyRelation {
-			isSlice := fieldValue.Type().Kind() == reflect.Slice
-
 			// No relations of the given name were provided
 			if data.Relationships == nil || data.Relationships[args[1]] == nil {
 				continue
 			}
-
-			// If this is a polymorphic relation, each data relationship needs to be assigned
-			// to it's appropriate choice field and fieldValue should be a choice
-			// struct type field.
-			var choiceMapping map[string]structFieldIndex = nil
-			if annotation == annotationPolyRelation {
-				choiceMapping = choiceStructMapping(fieldValue.Type())
+			if d.patch && !hasLinkage(data.Relationships[args[1]]) {
+				continue
 			}
 
-			if isSlice {
-				// to-many relationship
-				relationship := new(RelationshipManyNode)
-				sliceType := fieldValue.Type()
-
-				buf := bytes.NewBuffer(nil)
-
-				json.NewEncoder(buf).Encode(data.Relationships[args[1]]) //nolint:errcheck
-				json.NewDecoder(buf).Decode(relationship)                //nolint:errcheck
-
-				data := relationship.Data
-
-				// This will hold either the value of the slice of choice type models or
-				// the slice of models, depending on the annotation
-				models := reflect.New(sliceType).Elem()
-
-				for _, n := range data {
-					// This will hold either the value of the choice type model or the actual
-					// model, depending on annotation
-					m := reflect.New(sliceType.Elem().Elem())
-
-					err = unmarshalNodeMaybeChoice(&m, n, annotation, choiceMapping, included)
-					if err != nil {
-						er = err
-						break
-					}
-
-					models = reflect.Append(models, m)
-				}
-
-				if len(data) == 0 {
-					// создаём пустой slice нужного типа, чтобы он не был nil
-					emptySlice := reflect.MakeSlice(sliceType, 0, 0)
-					fieldValue.Set(emptySlice)
-				} else {
-					fieldValue.Set(models)
-				}
-			} else {
-				// to-one relationships
-				relationship := new(RelationshipOneNode)
-
-				buf := bytes.NewBuffer(nil)
-				relDataStr := data.Relationships[args[1]]
-				json.NewEncoder(buf).Encode(relDataStr) //nolint:errcheck
-
-				isExplicitNull := false
-				relationshipDecodeErr := json.NewDecoder(buf).Decode(relationship)
-				if relationshipDecodeErr == nil && relationship.Data == nil {
-					// If the relationship was a valid node and relationship data was null
-					// this indicates disassociating the relationship
-					isExplicitNull = true
-				} else if relationshipDecodeErr != nil {
-					er = fmt.Errorf("Could not unmarshal json: %w", relationshipDecodeErr)
-				}
-
-				// This will hold either the value of the choice type model or the actual
-				// model, depending on annotation
-				m := reflect.New(fieldValue.Type().Elem())
-
-				// Nullable relationships have an extra pointer indirection
-				// unwind that here
-				if strings.HasPrefix(fieldType.Type.Name(), "NullableRelationship[") {
-					if m.Kind() == reflect.Ptr {
-						m = reflect.New(fieldValue.Type().Elem().Elem())
-					}
-				}
-				/*
-					http://jsonapi.org/format/#document-resource-object-relationships
-					http://jsonapi.org/format/#document-resource-object-linkage
-					relationship can have a data node set to null (e.g. to disassociate the relationship)
-					so unmarshal and set fieldValue only if data obj is not null
-				*/
-				if relationship.Data == nil {
-					// Explicit null supplied for the field value
-					// If a nullable relationship we set the field value to a map with a single entry
-					if isExplicitNull && strings.HasPrefix(fieldType.Type.Name(), "NullableRelationship[") {
-						fieldValue.Set(reflect.MakeMapWithSize(fieldValue.Type(), 1))
-						fieldValue.SetMapIndex(reflect.ValueOf(false), m)
-					}
-					continue
-				}
-
-				// If the field is also a polyrelation field, then prefer the polyrelation.
-				// Otherwise stop processing this node.
-				// This is to allow relation and polyrelation fields to coexist, supporting deprecation for consumers
-				if pFieldType, ok := polyrelationFields[args[1]]; ok && fieldValue.Type() != pFieldType {
-					continue
-				}
-
-				err = unmarshalNodeMaybeChoice(&m, relationship.Data, annotation, choiceMapping, included)
-				if err != nil {
-					er = err
-					break
-				}
-
-				if strings.HasPrefix(fieldType.Type.Name(), "NullableRelationship[") {
-					fieldValue.Set(reflect.MakeMapWithSize(fieldValue.Type(), 1))
-					fieldValue.SetMapIndex(reflect.ValueOf(true), m)
-				} else {
-					fieldValue.Set(m)
-				}
+			relPointer := jsonPointer(loc.node, "relationships", args[1])
+			er = d.unmarshalRelation(data.Relationships[args[1]], relPointer, fieldValue, fieldType, args, polyrelationFields)
+			if er != nil {
+				break
 			}
 		} else if annotation == annotationLinks {
 			if data.Links == nil {
 				continue
 			}
 
-			links := make(Links, len(*data.Links))
-
-			for k, v := range *data.Links {
-				link := v // default case (including string urls)
-
-				// Unmarshal link objects to Link
-				if t, ok := v.(map[string]interface{}); ok {
-					unmarshaledHref := ""
-					href, ok := t["href"].(string)
-					if ok {
-						unmarshaledHref = href
-					}
-
-					unmarshaledMeta := make(Meta)
-					if meta, ok := t["meta"].(map[string]interface{}); ok {
-						for metaK, metaV := range meta {
-							unmarshaledMeta[metaK] = metaV
-						}
-					}
-
-					link = Link{
-						Href: unmarshaledHref,
-						Meta: unmarshaledMeta,
-					}
-				}
-
-				links[k] = link
-			}
-
-			if err != nil {
-				er = err
-				break
-			}
-
-			assign(fieldValue, reflect.ValueOf(links))
+			assign(fieldValue, reflect.ValueOf(unmarshalLinks(data.Links)))
 		} else if annotation == annotationMeta {
 			if data.Meta == nil {
 				continue
 			}
 
-			links := make(Meta, len(*data.Meta))
-
-			for k, v := range *data.Meta {
-				link := v // default case (including string urls)
-				links[k] = link
+			assign(fieldValue, reflect.ValueOf(unmarshalMeta(data.Meta)))
+		} else if annotation == annotationRelLinks || annotation == annotationRelMeta {
+			relationship, ok := data.Relationships[args[1]].(map[string]interface{})
+			if !ok {
+				continue
 			}
 
-			if err != nil {
-				er = err
-				break
+			if annotation == annotationRelLinks {
+				if links, ok := relationship["links"].(map[string]interface{}); ok {
+					assign(fieldValue, reflect.ValueOf(unmarshalLinks((*Links)(&links))))
+				}
+			} else if meta, ok := relationship["meta"].(map[string]interface{}); ok {
+				assign(fieldValue, reflect.ValueOf(unmarshalMeta((*Meta)(&meta))))
 			}
-
-			assign(fieldValue, reflect.ValueOf(links))
 		} else {
 			er = fmt.Errorf(unsupportedStructTagMsg, annotation)
 		}
 	}
 
+	// Structs without jsonapi annotations are only ever nested attributes
+	// with no members to compare against.
+	if er == nil && d.opts.disallowUnknownMembers && hasJSONAPIAnnotations(modelType) {
+		er = d.checkNodeMembers(data, loc, knownAttributes, knownRelationships)
+	}
+
 	return er
 }
 
-func unmarshalNodeWithLidMap(data *Node, model reflect.Value, included *map[string]*Node, generator IDGenerator, lidMap LidMap) (err error) {
-	if generator == nil {
-		return fmt.Errorf(notNilGeneratorError)
+// unmarshalRelation decodes the relationship object relationship, found at
+// pointer, into the `relation` or `polyrelation` field fieldValue.
+// polyrelationFields maps the names of the model's polyrelation fields to
+// their types, so that a relation field sharing its name with one is left
+// unset in favour of the polyrelation.
+func (d *decoder) unmarshalRelation(
+	relationship interface{},
+	pointer string,
+	fieldValue reflect.Value,
+	fieldType reflect.StructField,
+	args []string,
+	polyrelationFields map[string]reflect.Type,
+) error {
+	if strings.HasPrefix(fieldValue.Type().Name(), "NullableRelationship[") {
+		return d.unmarshalNullableRelation(relationship, pointer, fieldValue, fieldType, args, polyrelationFields)
 	}
-	defer func() {
-		if r := recover(); r != nil {
-			err = fmt.Errorf("data is not a jsonapi representation of '%v'", model.Type())
-		}
-	}()
 
-	modelValue := model.Elem()
-	modelType := modelValue.Type()
-	polyrelationFields := map[string]reflect.Type{}
+	annotation := args[0]
+	isSlice := fieldValue.Type().Kind() == reflect.Slice
 
-	var er error
+	// If this is a polymorphic relation, each data relationship needs to be assigned
+	// to it's appropriate choice field and fieldValue should be a choice
+	// struct type field.
+	var choiceMapping map[string]structFieldIndex = nil
+	if annotation == annotationPolyRelation {
+		choiceMapping = choiceStructMapping(fieldValue.Type())
+	}
 
-	// preprocess the model to find polyrelation fields
-	for i := 0; i < modelValue.NumField(); i++ {
-		fieldValue := modelValue.Field(i)
-		fieldType := modelType.Field(i)
+	if isSlice {
+		// to-many relationship
+		relationshipNode := new(RelationshipManyNode)
+		sliceType := fieldValue.Type()
 
-		args, err := getStructTags(fieldType)
-		if err != nil {
-			er = err
-			break
-		}
+		buf := bytes.NewBuffer(nil)
 
-		if len(args) < 2 {
-			continue
-		}
+		json.NewEncoder(buf).Encode(relationship)      //nolint:errcheck
+		newNumberDecoder(buf).Decode(relationshipNode) //nolint:errcheck
 
-		annotation := args[0]
-		name := args[1]
+		data := relationshipNode.Data
 
-		if annotation == annotationPolyRelation {
-			polyrelationFields[name] = fieldValue.Type()
-		}
-	}
+		// This will hold either the value of the slice of choice type models or
+		// the slice of models, depending on the annotation
+		models := reflect.New(sliceType).Elem()
 
-	for i := 0; i < modelValue.NumField(); i++ {
-		fieldValue := modelValue.Field(i)
-		fieldType := modelType.Field(i)
+		for j, n := range data {
+			// This will hold either the value of the choice type model or the actual
+			// model, depending on annotation
+			m := newRelatedModel(sliceType.Elem())
 
-		args, err := getStructTags(fieldType)
-		if err != nil {
-			er = err
-			break
-		}
-		if len(args) == 0 {
-			continue
-		}
-		annotation := args[0]
-
-		if annotation == annotationPrimary {
-			// Check the JSON API Type
-			if data.Type != args[1] {
-				er = fmt.Errorf(
-					"Trying to Unmarshal an object of type %#v, but %#v does not match",
-					data.Type,
-					args[1],
-				)
-				break
-			}
-
-			if data.ID == "" {
-				if data.Lid == "" {
-					continue
-				}
-				if lidMap.Exist(data.Lid) {
-					id := lidMap.Get(data.Lid)
-					data.ID = id
-				} else {
-					newId, err := generator.Generate()
-					if err != nil {
-						return fmt.Errorf(generateError)
-					}
-					lidMap.Set(data.Lid, newId)
-				}
-			}
-
-			// ID will have to be transmitted as astring per the JSON API spec
-			v := reflect.ValueOf(data.ID)
-
-			// Deal with PTRS
-			var kind reflect.Kind
-			if fieldValue.Kind() == reflect.Ptr {
-				kind = fieldType.Type.Elem().Kind()
-			} else {
-				kind = fieldType.Type.Kind()
-			}
-
-			// Handle String case
-			if kind == reflect.String {
-				assign(fieldValue, v)
-				continue
-			}
-
-			// Value was not a string... only other supported type was a numeric,
-			// which would have been sent as a float value.
-			floatValue, err := strconv.ParseFloat(data.ID, 64)
-			if err != nil {
-				// Could not convert the value in the "id" attr to a float
-				er = ErrBadJSONAPIID
-				break
-			}
-
-			// Convert the numeric float to one of the supported ID numeric types
-			// (int[8,16,32,64] or uint[8,16,32,64])
-			idValue, err := handleNumeric(floatValue, fieldType.Type, fieldValue)
+			nLoc := resourceAt(jsonPointer(pointer, "data", strconv.Itoa(j)))
+			err := d.unmarshalNodeMaybeChoice(&m, n, nLoc, fieldType.Name, annotation, choiceMapping)
 			if err != nil {
-				// We had a JSON float (numeric), but our field was not one of the
-				// allowed numeric types
-				er = ErrBadJSONAPIID
-				break
-			}
-
-			assign(fieldValue, idValue)
-		} else if annotation == annotationClientID {
-			if data.ClientID == "" {
-				continue
-			}
-
-			fieldValue.Set(reflect.ValueOf(data.ClientID))
-		} else if annotation == annotationAttribute {
-			attributes := data.Attributes
-
-			if attributes == nil || len(data.Attributes) == 0 {
-				continue
-			}
-
-			attribute, found := attributes[args[1]]
-
-			// continue if the attribute was not included in the request
-			if !found {
-				continue
-			}
-
-			// explicit null for NullableAttr[T] should be preserved
-			if attribute == nil {
-				if strings.HasPrefix(fieldType.Type.Name(), "NullableAttr[") {
-					fieldValue.Set(reflect.MakeMapWithSize(fieldValue.Type(), 1))
-					fieldValue.SetMapIndex(reflect.ValueOf(false), reflect.Zero(fieldValue.Type().Elem()))
-				} else if fieldValue.Kind() == reflect.Ptr {
-					fieldValue.Set(reflect.Zero(fieldValue.Type()))
+				if err := d.report(err); err != nil {
+					return err
 				}
 				continue
 			}
 
-			structField := fieldType
-			value, err := unmarshalAttribute(attribute, args, structField, fieldValue)
-			if err != nil {
-				er = err
-				break
-			}
+			models = reflect.Append(models, m)
+		}
 
-			assign(fieldValue, value)
-		} else if annotation == annotationRelation || annotation == annotationPolyRelation {
-			isSlice := fieldValue.Type().Kind() == reflect.Slice
+		if len(data) == 0 {
+			// создаём пустой slice нужного типа, чтобы он не был nil
+			emptySlice := reflect.MakeSlice(sliceType, 0, 0)
+			fieldValue.Set(emptySlice)
+		} else {
+			fieldValue.Set(models)
+		}
+		return nil
+	}
 
-			// No relations of the given name were provided
-			if data.Relationships == nil || data.Relationships[args[1]] == nil {
-				continue
-			}
+	// to-one relationships
+	relationshipNode := new(RelationshipOneNode)
 
-			// If this is a polymorphic relation, each data relationship needs to be assigned
-			// to it's appropriate choice field and fieldValue should be a choice
-			// struct type field.
-			var choiceMapping map[string]structFieldIndex = nil
-			if annotation == annotationPolyRelation {
-				choiceMapping = choiceStructMapping(fieldValue.Type())
-			}
+	buf := bytes.NewBuffer(nil)
+	json.NewEncoder(buf).Encode(relationship) //nolint:errcheck
 
-			if isSlice {
-				// to-many relationship
-				relationship := new(RelationshipManyNode)
-				sliceType := fieldValue.Type()
+	if relationshipDecodeErr := newNumberDecoder(buf).Decode(relationshipNode); relationshipDecodeErr != nil {
+		if err := d.report(newUnmarshalError(fmt.Errorf("Could not unmarshal json: %w", relationshipDecodeErr), pointer, fieldType.Name)); err != nil {
+			return err
+		}
+	}
 
-				buf := bytes.NewBuffer(nil)
+	// This will hold either the value of the choice type model or the actual
+	// model, depending on annotation
+	m := newRelatedModel(fieldValue.Type())
+
+	/*
+		http://jsonapi.org/format/#document-resource-object-relationships
+		http://jsonapi.org/format/#document-resource-object-linkage
+		relationship can have a data node set to null (e.g. to disassociate the relationship)
+		so unmarshal and set fieldValue only if data obj is not null
+	*/
+	if relationshipNode.Data == nil {
+		if d.patch {
+			fieldValue.Set(reflect.Zero(fieldValue.Type()))
+		}
+		return nil
+	}
 
-				json.NewEncoder(buf).Encode(data.Relationships[args[1]]) //nolint:errcheck
-				json.NewDecoder(buf).Decode(relationship)                //nolint:errcheck
+	// If the field is also a polyrelation field, then prefer the polyrelation.
+	// Otherwise stop processing this node.
+	// This is to allow relation and polyrelation fields to coexist, supporting deprecation for consumers
+	if pFieldType, ok := polyrelationFields[args[1]]; ok && fieldValue.Type() != pFieldType {
+		return nil
+	}
 
-				data := relationship.Data
+	dataLoc := resourceAt(jsonPointer(pointer, "data"))
+	err := d.unmarshalNodeMaybeChoice(&m, relationshipNode.Data, dataLoc, fieldType.Name, annotation, choiceMapping)
+	if err != nil {
+		return d.report(err)
+	}
 
-				// This will hold either the value of the slice of choice type models or
-				// the slice of models, depending on the annotation
-				models := reflect.New(sliceType).Elem()
+	fieldValue.Set(m)
+	return nil
+}
 
-				for _, n := range data {
-					if n.Lid != "" {
-						if lidMap.Exist(n.Lid) {
-							n.ID = lidMap.Get(n.Lid)
-						}
-					}
-					// This will hold either the value of the choice type model or the actual
-					// model, depending on annotation
-					m := reflect.New(sliceType.Elem().Elem())
+// unmarshalNullableRelation decodes the relationship object relationship,
+// found at pointer, into the NullableRelationship field fieldValue. An
+// explicit null "data" sets it to null, and any other linkage, including an
+// empty array for a to-many relationship, to the models it refers to,
+// decoded as for a field of the inner type. A relationship object without
+// "data" leaves it unspecified.
+func (d *decoder) unmarshalNullableRelation(
+	relationship interface{},
+	pointer string,
+	fieldValue reflect.Value,
+	fieldType reflect.StructField,
+	args []string,
+	polyrelationFields map[string]reflect.Type,
+) error {
+	// As for other relations, prefer a polyrelation field of the same name
+	if pFieldType, ok := polyrelationFields[args[1]]; ok && fieldValue.Type() != pFieldType {
+		return nil
+	}
 
-					err = unmarshalNodeMaybeChoiceWithLidMap(&m, n, annotation, choiceMapping, included, generator, lidMap)
-					if err != nil {
-						er = err
-						break
-					}
+	inner := reflect.New(fieldValue.Type().Elem()).Elem()
 
-					models = reflect.Append(models, m)
-				}
+	object, isObject := relationship.(map[string]interface{})
+	data, found := object["data"]
+	if isObject && !found {
+		return nil
+	}
 
-				if len(data) == 0 {
-					// создаём пустой slice нужного типа, чтобы он не был nil
-					emptySlice := reflect.MakeSlice(sliceType, 0, 0)
-					fieldValue.Set(emptySlice)
-				} else {
-					fieldValue.Set(models)
-				}
-			} else {
-				// to-one relationships
-				relationship := new(RelationshipOneNode)
-
-				buf := bytes.NewBuffer(nil)
-				relDataStr := data.Relationships[args[1]]
-				json.NewEncoder(buf).Encode(relDataStr) //nolint:errcheck
-
-				isExplicitNull := false
-				relationshipDecodeErr := json.NewDecoder(buf).Decode(relationship)
-				if relationshipDecodeErr == nil && relationship.Data == nil {
-					// If the relationship was a valid node and relationship data was null
-					// this indicates disassociating the relationship
-					isExplicitNull = true
-				} else if relationshipDecodeErr != nil {
-					er = fmt.Errorf("Could not unmarshal json: %w", relationshipDecodeErr)
-				}
+	if isObject && data == nil {
+		fieldValue.Set(reflect.MakeMapWithSize(fieldValue.Type(), 1))
+		fieldValue.SetMapIndex(reflect.ValueOf(false), inner)
+		return nil
+	}
 
-				if relationship.Data.Lid != "" {
-					if lidMap.Exist(relationship.Data.Lid) {
-						relationship.Data.ID = lidMap.Get(relationship.Data.Lid)
-					}
-				}
+	if err := d.unmarshalRelation(relationship, pointer, inner, fieldType, args, nil); err != nil {
+		return err
+	}
 
-				// This will hold either the value of the choice type model or the actual
-				// model, depending on annotation
-				m := reflect.New(fieldValue.Type().Elem())
+	fieldValue.Set(reflect.MakeMapWithSize(fieldValue.Type(), 1))
+	fieldValue.SetMapIndex(reflect.ValueOf(true), inner)
+	return nil
+}
 
-				// Nullable relationships have an extra pointer indirection
-				// unwind that here
-				if strings.HasPrefix(fieldType.Type.Name(), "NullableRelationship[") {
-					if m.Kind() == reflect.Ptr {
-						m = reflect.New(fieldValue.Type().Elem().Elem())
-					}
-				}
-				/*
-					http://jsonapi.org/format/#document-resource-object-relationships
-					http://jsonapi.org/format/#document-resource-object-linkage
-					relationship can have a data node set to null (e.g. to disassociate the relationship)
-					so unmarshal and set fieldValue only if data obj is not null
-				*/
-				if relationship.Data == nil {
-					// Explicit null supplied for the field value
-					// If a nullable relationship we set the field value to a map with a single entry
-					if isExplicitNull && strings.HasPrefix(fieldType.Type.Name(), "NullableRelationship[") {
-						fieldValue.Set(reflect.MakeMapWithSize(fieldValue.Type(), 1))
-						fieldValue.SetMapIndex(reflect.ValueOf(false), m)
-					}
-					continue
-				}
+// unmarshalLinks returns a copy of the decoded links object with link
+// objects converted to Link, or nil if there is none.
+func unmarshalLinks(in *Links) *Links {
+	if in == nil {
+		return nil
+	}
 
-				// If the field is also a polyrelation field, then prefer the polyrelation.
-				// Otherwise stop processing this node.
-				// This is to allow relation and polyrelation fields to coexist, supporting deprecation for consumers
-				if pFieldType, ok := polyrelationFields[args[1]]; ok && fieldValue.Type() != pFieldType {
-					continue
-				}
+	links := make(Links, len(*in))
 
-				err = unmarshalNodeMaybeChoiceWithLidMap(&m, relationship.Data, annotation, choiceMapping, included, generator, lidMap)
-				if err != nil {
-					er = err
-					break
-				}
+	for k, v := range *in {
+		link := normalizeNumbers(v) // default case (including string urls)
 
-				if strings.HasPrefix(fieldType.Type.Name(), "NullableRelationship[") {
-					fieldValue.Set(reflect.MakeMapWithSize(fieldValue.Type(), 1))
-					fieldValue.SetMapIndex(reflect.ValueOf(true), m)
-				} else {
-					fieldValue.Set(m)
-				}
-			}
-		} else if annotation == annotationLinks {
-			if data.Links == nil {
-				continue
+		// Unmarshal link objects to Link
+		if t, ok := v.(map[string]interface{}); ok {
+			unmarshaledHref := ""
+			href, ok := t["href"].(string)
+			if ok {
+				unmarshaledHref = href
 			}
 
-			links := make(Links, len(*data.Links))
-
-			for k, v := range *data.Links {
-				link := v // default case (including string urls)
-
-				// Unmarshal link objects to Link
-				if t, ok := v.(map[string]interface{}); ok {
-					unmarshaledHref := ""
-					href, ok := t["href"].(string)
-					if ok {
-						unmarshaledHref = href
-					}
-
-					unmarshaledMeta := make(Meta)
-					if meta, ok := t["meta"].(map[string]interface{}); ok {
-						for metaK, metaV := range meta {
-							unmarshaledMeta[metaK] = metaV
-						}
-					}
-
-					link = Link{
-						Href: unmarshaledHref,
-						Meta: unmarshaledMeta,
-					}
+			unmarshaledMeta := make(Meta)
+			if meta, ok := t["meta"].(map[string]interface{}); ok {
+				for metaK, metaV := range meta {
+					unmarshaledMeta[metaK] = normalizeNumbers(metaV)
 				}
-
-				links[k] = link
 			}
 
-			if err != nil {
-				er = err
-				break
-			}
-
-			assign(fieldValue, reflect.ValueOf(links))
-		} else if annotation == annotationMeta {
-			if data.Meta == nil {
-				continue
+			link = Link{
+				Href: unmarshaledHref,
+				Meta: unmarshaledMeta,
 			}
+		}
 
-			links := make(Meta, len(*data.Meta))
+		links[k] = link
+	}
 
-			for k, v := range *data.Meta {
-				link := v // default case (including string urls)
-				links[k] = link
-			}
+	return &links
+}
 
-			if err != nil {
-				er = err
-				break
-			}
+// unmarshalMeta returns a copy of the decoded meta object with its numbers
+// converted to float64, or nil if there is none.
+func unmarshalMeta(in *Meta) *Meta {
+	if in == nil {
+		return nil
+	}
 
-			assign(fieldValue, reflect.ValueOf(links))
-		} else {
-			er = fmt.Errorf(unsupportedStructTagMsg, annotation)
-		}
+	meta := make(Meta, len(*in))
+	for k, v := range *in {
+		meta[k] = normalizeNumbers(v)
 	}
 
-	return er
+	return &meta
 }
 
-func fullNode(n *Node, included *map[string]*Node) *Node {
-	if n.ID == "" && n.Lid != "" {
-		n.ID = n.Lid
+// fullNode returns the included resource that the linkage n refers to, along
+// with its location, or n itself when the resource was not sideloaded.
+func (d *decoder) fullNode(n *Node, loc location) (*Node, location, error) {
+	if err := d.resolveLid(n, loc); err != nil {
+		return nil, loc, err
 	}
-	includedKey := fmt.Sprintf("%s,%s", n.Type, n.ID)
-	if included != nil && (*included)[includedKey] != nil {
-		return (*included)[includedKey]
+
+	includedKey := resourceKey(n)
+	if full := d.included[includedKey]; full != nil {
+		return full, resourceAt(jsonPointer("/included", strconv.Itoa(d.includedIndex[includedKey]))), nil
 	}
 
-	return n
+	return n, loc, nil
 }
 
 // assign will take the value specified and assign it to the field; if
@@ -1106,23 +1223,32 @@ func assignValue(field, value reflect.Value) {
 	}
 }
 
-func unmarshalAttribute(
+// unmarshalAttribute converts the decoded JSON value of an attribute into a
+// value assignable to fieldValue. pointer locates the attribute within the
+// request document and is used when decoding nested struct attributes.
+func (d *decoder) unmarshalAttribute(
 	attribute interface{},
 	args []string,
 	structField reflect.StructField,
-	fieldValue reflect.Value) (value reflect.Value, err error) {
+	fieldValue reflect.Value,
+	pointer string) (value reflect.Value, err error) {
+	fieldType := fieldValue.Type()
+
+	// Numbers may be given as strings with the "string" option
+	if s, ok := attribute.(string); ok && isQuotedNumber(args, fieldType) {
+		attribute = json.Number(s)
+	}
 	value = reflect.ValueOf(attribute)
-	fieldType := structField.Type
 
 	// Handle NullableAttr[T]
 	if strings.HasPrefix(fieldValue.Type().Name(), "NullableAttr[") {
-		value, err = handleNullable(attribute, args, structField, fieldValue)
+		value, err = d.handleNullable(attribute, args, structField, fieldValue, pointer)
 		return
 	}
 
-	// Handle field of type []string
-	if fieldValue.Type() == reflect.TypeOf([]string{}) {
-		value, err = handleStringSlice(attribute)
+	// Handle Optional[T]
+	if isOptional(fieldValue.Type()) {
+		value, err = d.handleOptional(attribute, args, structField, fieldValue, pointer)
 		return
 	}
 
@@ -1133,38 +1259,65 @@ func unmarshalAttribute(
 		return
 	}
 
+	// Handle types that know how to decode themselves, or slices and pointers
+	// of them
+	if decodesItself(fieldValue.Type()) {
+		value, err = handleUnmarshaler(attribute, fieldValue.Type())
+		return
+	}
+
 	if fieldValue.Type().Kind() == reflect.Interface {
-		return reflect.ValueOf(attribute), nil
+		return reflect.ValueOf(normalizeNumbers(attribute)), nil
 	}
 
 	// Handle field of type struct
 	if fieldValue.Type().Kind() == reflect.Struct {
-		value, err = handleStruct(attribute, fieldValue)
+		value, err = d.handleStruct(attribute, fieldValue, pointer)
 		return
 	}
 
 	// Handle field containing slice of structs
 	if fieldValue.Type().Kind() == reflect.Slice &&
-		reflect.TypeOf(fieldValue.Interface()).Elem().Kind() == reflect.Struct {
-		value, err = handleStructSlice(attribute, fieldValue)
+		isStructAttribute(fieldValue.Type().Elem()) {
+		value, err = d.handleStructSlice(attribute, fieldValue, pointer)
+		return
+	}
+
+	if fieldValue.Type().Kind() == reflect.Slice &&
+		fieldValue.Type().Elem().Kind() == reflect.Ptr &&
+		isStructAttribute(fieldValue.Type().Elem().Elem()) {
+		value, err = d.handleStructPointerSlice(attribute, args, fieldValue, pointer)
 		return
 	}
 
+	// Handle field of type []byte, which encoding/json encodes as base64
 	if fieldValue.Type().Kind() == reflect.Slice &&
-		reflect.TypeOf(fieldValue.Interface()).Elem().Kind() == reflect.Ptr {
-		value, err = handleStructPointerSlice(attribute, args, fieldValue)
+		fieldValue.Type().Elem().Kind() == reflect.Uint8 {
+		value, err = handleUnmarshaler(attribute, fieldValue.Type())
+		return
+	}
+
+	// Handle field of any other slice or array type
+	if fieldValue.Type().Kind() == reflect.Slice || fieldValue.Type().Kind() == reflect.Array {
+		value, err = d.handleSlice(attribute, args, structField, fieldValue, pointer)
+		return
+	}
+
+	// Handle field of any map type
+	if fieldValue.Type().Kind() == reflect.Map {
+		value, err = d.handleMap(attribute, args, structField, fieldValue, pointer)
 		return
 	}
 
-	// JSON value was a float (numeric)
-	if value.Kind() == reflect.Float64 {
+	// JSON value was a number
+	if _, ok := attribute.(json.Number); ok || value.Kind() == reflect.Float64 {
 		value, err = handleNumeric(attribute, fieldType, fieldValue)
 		return
 	}
 
 	// Field was a Pointer type
 	if fieldValue.Kind() == reflect.Ptr {
-		value, err = handlePointer(attribute, args, fieldType, fieldValue, structField)
+		value, err = d.handlePointer(attribute, args, fieldType, fieldValue, structField, pointer)
 		return
 	}
 
@@ -1177,21 +1330,135 @@ func unmarshalAttribute(
 	return
 }
 
-func handleStringSlice(attribute interface{}) (reflect.Value, error) {
-	v := reflect.ValueOf(attribute)
-	values := make([]string, v.Len())
-	for i := 0; i < v.Len(); i++ {
-		values[i] = v.Index(i).Interface().(string)
+// isStructAttribute reports whether t is decoded as a nested struct
+// attribute. time.Time is a struct but is decoded by handleTime.
+func isStructAttribute(t reflect.Type) bool {
+	return t.Kind() == reflect.Struct && t != reflect.TypeOf(time.Time{})
+}
+
+// handleMap decodes a JSON object into a map of any type, decoding each
+// member as though it were an attribute of the map's element type.
+func (d *decoder) handleMap(
+	attribute interface{},
+	args []string,
+	structField reflect.StructField,
+	fieldValue reflect.Value,
+	pointer string) (reflect.Value, error) {
+
+	members, ok := attribute.(map[string]interface{})
+	if !ok {
+		return reflect.Value{}, ErrInvalidType
+	}
+
+	mapType := fieldValue.Type()
+	m := reflect.MakeMapWithSize(mapType, len(members))
+	for _, name := range sortedKeys(members) {
+		key, err := mapKey(name, mapType.Key())
+		if err != nil {
+			if err := d.report(newUnmarshalError(err, jsonPointer(pointer, name), "")); err != nil {
+				return reflect.Value{}, err
+			}
+			continue
+		}
+
+		elem := reflect.New(mapType.Elem()).Elem()
+		if members[name] != nil {
+			value, err := d.unmarshalAttribute(members[name], args, structField, elem, jsonPointer(pointer, name))
+			if err != nil {
+				// Members that cannot be decoded are left out when all errors
+				// are being collected.
+				if err := d.report(newUnmarshalError(err, jsonPointer(pointer, name), "")); err != nil {
+					return reflect.Value{}, err
+				}
+				continue
+			}
+			assign(elem, value)
+		}
+
+		m.SetMapIndex(key, elem)
+	}
+
+	return m, nil
+}
+
+// mapKey converts the name of a JSON object member into a map key of type
+// t. As with encoding/json, string and integer keys are supported.
+func mapKey(name string, t reflect.Type) (reflect.Value, error) {
+	key := reflect.New(t).Elem()
+
+	switch t.Kind() {
+	case reflect.String:
+		key.SetString(name)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(name, 10, t.Bits())
+		if err != nil {
+			return reflect.Value{}, ErrInvalidType
+		}
+		key.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		n, err := strconv.ParseUint(name, 10, t.Bits())
+		if err != nil {
+			return reflect.Value{}, ErrInvalidType
+		}
+		key.SetUint(n)
+	default:
+		return reflect.Value{}, ErrInvalidType
 	}
 
-	return reflect.ValueOf(values), nil
+	return key, nil
 }
 
-func handleNullable(
+// handleSlice decodes a JSON array into a slice or array of any type,
+// decoding each element as though it were an attribute of the element type.
+func (d *decoder) handleSlice(
 	attribute interface{},
 	args []string,
 	structField reflect.StructField,
-	fieldValue reflect.Value) (reflect.Value, error) {
+	fieldValue reflect.Value,
+	pointer string) (reflect.Value, error) {
+
+	elems, ok := attribute.([]interface{})
+	if !ok {
+		return reflect.Value{}, ErrInvalidType
+	}
+
+	var s reflect.Value
+	if t := fieldValue.Type(); t.Kind() == reflect.Array {
+		if len(elems) > t.Len() {
+			return reflect.Value{}, ErrInvalidType
+		}
+		s = reflect.New(t).Elem()
+	} else {
+		s = reflect.MakeSlice(t, len(elems), len(elems))
+	}
+
+	for i, data := range elems {
+		if data == nil {
+			continue
+		}
+
+		elemPointer := jsonPointer(pointer, strconv.Itoa(i))
+		value, err := d.unmarshalAttribute(data, args, structField, s.Index(i), elemPointer)
+		if err != nil {
+			// Elements that cannot be decoded are left as zero values when all
+			// errors are being collected.
+			if err := d.report(newUnmarshalError(err, elemPointer, "")); err != nil {
+				return reflect.Value{}, err
+			}
+			continue
+		}
+		assign(s.Index(i), value)
+	}
+
+	return s, nil
+}
+
+func (d *decoder) handleNullable(
+	attribute interface{},
+	args []string,
+	structField reflect.StructField,
+	fieldValue reflect.Value,
+	pointer string) (reflect.Value, error) {
 
 	if a, ok := attribute.(string); ok && a == "null" {
 		return reflect.ValueOf(nil), nil
@@ -1200,76 +1467,96 @@ func handleNullable(
 	innerType := fieldValue.Type().Elem()
 	zeroValue := reflect.Zero(innerType)
 
-	attrVal, err := unmarshalAttribute(attribute, args, structField, zeroValue)
+	attrVal, err := d.unmarshalAttribute(attribute, args, structField, zeroValue, pointer)
 	if err != nil {
 		return reflect.ValueOf(nil), err
 	}
 
+	// attrVal may be a pointer to the inner type, as returned for numerics
+	inner := reflect.New(innerType).Elem()
+	assign(inner, attrVal)
+
 	fieldValue.Set(reflect.MakeMapWithSize(fieldValue.Type(), 1))
-	fieldValue.SetMapIndex(reflect.ValueOf(true), attrVal)
+	fieldValue.SetMapIndex(reflect.ValueOf(true), inner)
 
 	return fieldValue, nil
 }
 
-func handleTime(attribute interface{}, args []string, fieldValue reflect.Value) (reflect.Value, error) {
-	var isISO8601, isRFC3339 bool
-	v := reflect.ValueOf(attribute)
-
-	if len(args) > 2 {
-		for _, arg := range args[2:] {
-			if arg == annotationISO8601 {
-				isISO8601 = true
-			} else if arg == annotationRFC3339 {
-				isRFC3339 = true
-			}
-		}
+func (d *decoder) handleOptional(
+	attribute interface{},
+	args []string,
+	structField reflect.StructField,
+	fieldValue reflect.Value,
+	pointer string) (reflect.Value, error) {
+
+	o := optionalOf(fieldValue)
+	innerType := o.reflectValue().Type()
+
+	attrVal, err := d.unmarshalAttribute(attribute, args, structField, reflect.Zero(innerType), pointer)
+	if err != nil {
+		return reflect.ValueOf(nil), err
 	}
 
-	if isISO8601 {
-		if v.Kind() != reflect.String {
-			return reflect.ValueOf(time.Now()), ErrInvalidISO8601
-		}
+	// attrVal may be a pointer to the inner type, as returned for numerics
+	inner := reflect.New(innerType).Elem()
+	assign(inner, attrVal)
+	o.setReflectValue(inner)
 
-		t, err := time.Parse(iso8601TimeFormat, v.Interface().(string))
-		if err != nil {
-			return reflect.ValueOf(time.Now()), ErrInvalidISO8601
-		}
+	return fieldValue, nil
+}
 
-		if fieldValue.Kind() == reflect.Ptr {
-			return reflect.ValueOf(&t), nil
-		}
+var (
+	jsonUnmarshalerType = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
 
-		return reflect.ValueOf(t), nil
+// decodesItself reports whether t, or the element type of a pointer, slice
+// or array type t, implements json.Unmarshaler or encoding.TextUnmarshaler.
+// time.Time is left to handleTime so that its tag options are honoured.
+func decodesItself(t reflect.Type) bool {
+	for t.Kind() == reflect.Ptr || t.Kind() == reflect.Slice || t.Kind() == reflect.Array {
+		t = t.Elem()
 	}
 
-	if isRFC3339 {
-		if v.Kind() != reflect.String {
-			return reflect.ValueOf(time.Now()), ErrInvalidRFC3339
-		}
+	if t == reflect.TypeOf(time.Time{}) {
+		return false
+	}
 
-		t, err := time.Parse(time.RFC3339, v.Interface().(string))
-		if err != nil {
-			return reflect.ValueOf(time.Now()), ErrInvalidRFC3339
-		}
+	pt := reflect.PtrTo(t)
+	return pt.Implements(jsonUnmarshalerType) || pt.Implements(textUnmarshalerType)
+}
 
-		if fieldValue.Kind() == reflect.Ptr {
-			return reflect.ValueOf(&t), nil
-		}
+// handleUnmarshaler delegates decoding of an attribute to its type's
+// UnmarshalJSON or UnmarshalText method by way of encoding/json.
+func handleUnmarshaler(attribute interface{}, t reflect.Type) (reflect.Value, error) {
+	data, err := json.Marshal(attribute)
+	if err != nil {
+		return reflect.Value{}, err
+	}
+
+	// A pointer to the decoded value is returned either way; assign
+	// allocates a fresh pointer for pointer fields.
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
 
-		return reflect.ValueOf(t), nil
+	value := reflect.New(t)
+	if err := json.Unmarshal(data, value.Interface()); err != nil {
+		return reflect.Value{}, err
 	}
 
-	var at int64
+	return value, nil
+}
 
-	if v.Kind() == reflect.Float64 {
-		at = int64(v.Interface().(float64))
-	} else if v.Kind() == reflect.Int {
-		at = v.Int()
-	} else {
-		return reflect.ValueOf(time.Now()), ErrInvalidTime
+func handleTime(attribute interface{}, args []string, fieldValue reflect.Value) (reflect.Value, error) {
+	t, err := parseTimeFormat(args).parse(attribute)
+	if err != nil {
+		return reflect.ValueOf(time.Now()), err
 	}
 
-	t := time.Unix(at, 0)
+	if fieldValue.Kind() == reflect.Ptr {
+		return reflect.ValueOf(&t), nil
+	}
 
 	return reflect.ValueOf(t), nil
 }
@@ -1278,55 +1565,47 @@ func handleNumeric(
 	attribute interface{},
 	fieldType reflect.Type,
 	fieldValue reflect.Value) (reflect.Value, error) {
-	v := reflect.ValueOf(attribute)
-	floatValue := v.Interface().(float64)
+	var number string
+	switch n := attribute.(type) {
+	case json.Number:
+		number = n.String()
+	case float64:
+		number = strconv.FormatFloat(n, 'g', -1, 64)
+	}
 
-	var kind reflect.Kind
+	t := fieldType
 	if fieldValue.Kind() == reflect.Ptr {
-		kind = fieldType.Elem().Kind()
-	} else {
-		kind = fieldType.Kind()
-	}
-
-	var numericValue reflect.Value
-
-	switch kind {
-	case reflect.Int:
-		n := int(floatValue)
-		numericValue = reflect.ValueOf(&n)
-	case reflect.Int8:
-		n := int8(floatValue)
-		numericValue = reflect.ValueOf(&n)
-	case reflect.Int16:
-		n := int16(floatValue)
-		numericValue = reflect.ValueOf(&n)
-	case reflect.Int32:
-		n := int32(floatValue)
-		numericValue = reflect.ValueOf(&n)
-	case reflect.Int64:
-		n := int64(floatValue)
-		numericValue = reflect.ValueOf(&n)
-	case reflect.Uint:
-		n := uint(floatValue)
-		numericValue = reflect.ValueOf(&n)
-	case reflect.Uint8:
-		n := uint8(floatValue)
-		numericValue = reflect.ValueOf(&n)
-	case reflect.Uint16:
-		n := uint16(floatValue)
-		numericValue = reflect.ValueOf(&n)
-	case reflect.Uint32:
-		n := uint32(floatValue)
-		numericValue = reflect.ValueOf(&n)
-	case reflect.Uint64:
-		n := uint64(floatValue)
-		numericValue = reflect.ValueOf(&n)
-	case reflect.Float32:
-		n := float32(floatValue)
-		numericValue = reflect.ValueOf(&n)
-	case reflect.Float64:
-		n := floatValue
-		numericValue = reflect.ValueOf(&n)
+		t = fieldType.Elem()
+	}
+
+	numericValue := reflect.New(t)
+	n := numericValue.Elem()
+
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := parseInteger(number)
+		if err != nil || n.OverflowInt(i) {
+			return reflect.Value{}, newNumberRangeError(number, t)
+		}
+		n.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		i, err := parseInteger(number)
+		if err != nil || i < 0 || n.OverflowUint(uint64(i)) {
+			// Values above math.MaxInt64 only fit a uint64
+			u, err := strconv.ParseUint(number, 10, 64)
+			if err != nil || n.OverflowUint(u) {
+				return reflect.Value{}, newNumberRangeError(number, t)
+			}
+			n.SetUint(u)
+			break
+		}
+		n.SetUint(uint64(i))
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(number, t.Bits())
+		if err != nil {
+			return reflect.Value{}, newNumberRangeError(number, t)
+		}
+		n.SetFloat(f)
 	default:
 		return reflect.Value{}, ErrUnknownFieldNumberType
 	}
@@ -1334,12 +1613,86 @@ func handleNumeric(
 	return numericValue, nil
 }
 
-func handlePointer(
+// parseInteger parses a JSON number that must be integral, such as 300 or
+// 3e2, without rounding it through a float64.
+func parseInteger(number string) (int64, error) {
+	if i, err := strconv.ParseInt(number, 10, 64); err == nil {
+		return i, nil
+	}
+
+	f, err := strconv.ParseFloat(number, 64)
+	if err != nil {
+		return 0, err
+	}
+	if f != math.Trunc(f) || f < math.MinInt64 || f >= math.MaxInt64 {
+		return 0, ErrNumberOutOfRange
+	}
+	return int64(f), nil
+}
+
+func newNumberRangeError(number string, t reflect.Type) error {
+	return fmt.Errorf("%w: %s into %s", ErrNumberOutOfRange, number, t)
+}
+
+// isQuotedNumber reports whether the attribute args include the "string"
+// option and t, or the type t points to, is numeric.
+func isQuotedNumber(args []string, t reflect.Type) bool {
+	if len(args) < 3 {
+		return false
+	}
+
+	quoted := false
+	for _, arg := range args[2:] {
+		if arg == annotationString {
+			quoted = true
+		}
+	}
+	if !quoted {
+		return false
+	}
+
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
+
+// normalizeNumbers replaces the json.Number values in v, which the decoder
+// produces, with float64 values, as encoding/json decodes numbers into
+// interface{} values.
+func normalizeNumbers(v interface{}) interface{} {
+	switch t := v.(type) {
+	case json.Number:
+		f, err := t.Float64()
+		if err != nil {
+			return v
+		}
+		return f
+	case map[string]interface{}:
+		for k, e := range t {
+			t[k] = normalizeNumbers(e)
+		}
+	case []interface{}:
+		for i, e := range t {
+			t[i] = normalizeNumbers(e)
+		}
+	}
+	return v
+}
+
+func (d *decoder) handlePointer(
 	attribute interface{},
 	args []string,
 	fieldType reflect.Type,
 	fieldValue reflect.Value,
-	structField reflect.StructField) (reflect.Value, error) {
+	structField reflect.StructField,
+	pointer string) (reflect.Value, error) {
 	t := fieldValue.Type()
 	var concreteVal reflect.Value
 
@@ -1352,7 +1705,7 @@ func handlePointer(
 		concreteVal = reflect.ValueOf(&cVal)
 	case map[string]interface{}:
 		var err error
-		concreteVal, err = handleStruct(attribute, fieldValue)
+		concreteVal, err = d.handleStruct(attribute, fieldValue, pointer)
 		if err != nil {
 			return reflect.Value{}, newErrUnsupportedPtrType(
 				reflect.ValueOf(attribute), fieldType, structField)
@@ -1371,9 +1724,20 @@ func handlePointer(
 	return concreteVal, nil
 }
 
-func handleStruct(
+func (d *decoder) handleStruct(
 	attribute interface{},
-	fieldValue reflect.Value) (reflect.Value, error) {
+	fieldValue reflect.Value,
+	pointer string) (reflect.Value, error) {
+
+	// Structs without jsonapi annotations are decoded by encoding/json,
+	// mirroring how they are encoded
+	structType := fieldValue.Type()
+	if structType.Kind() == reflect.Ptr {
+		structType = structType.Elem()
+	}
+	if structType.Kind() == reflect.Struct && !hasJSONAPIAnnotations(structType) {
+		return handleUnmarshaler(attribute, fieldValue.Type())
+	}
 
 	data, err := json.Marshal(attribute)
 	if err != nil {
@@ -1381,7 +1745,7 @@ func handleStruct(
 	}
 
 	node := new(Node)
-	if err := json.Unmarshal(data, &node.Attributes); err != nil {
+	if err := newNumberDecoder(bytes.NewReader(data)).Decode(&node.Attributes); err != nil {
 		return reflect.Value{}, err
 	}
 
@@ -1392,27 +1756,36 @@ func handleStruct(
 		model = reflect.New(fieldValue.Type())
 	}
 
-	if err := unmarshalNode(node, model, nil); err != nil {
+	// The members of a nested struct attribute sit directly inside the
+	// attribute value rather than in an "attributes" object.
+	if err := d.unmarshalNode(node, model, location{node: pointer, attributes: pointer}); err != nil {
 		return reflect.Value{}, err
 	}
 
 	return model, nil
 }
 
-func handleStructSlice(
+func (d *decoder) handleStructSlice(
 	attribute interface{},
-	fieldValue reflect.Value) (reflect.Value, error) {
+	fieldValue reflect.Value,
+	pointer string) (reflect.Value, error) {
 	models := reflect.New(fieldValue.Type()).Elem()
 	dataMap := reflect.ValueOf(attribute).Interface().([]interface{})
 	if len(dataMap) == 0 {
 		return reflect.MakeSlice(fieldValue.Type(), 0, 0), nil
 	}
-	for _, data := range dataMap {
+	for i, data := range dataMap {
 		model := reflect.New(fieldValue.Type().Elem()).Elem()
 
-		value, err := handleStruct(data, model)
+		elemPointer := jsonPointer(pointer, strconv.Itoa(i))
+		value, err := d.handleStruct(data, model, elemPointer)
 
 		if err != nil {
+			// Elements that cannot be decoded are skipped, but still reported
+			// when all errors are being collected.
+			if d.opts.collectErrors {
+				d.report(newUnmarshalError(err, elemPointer, "")) //nolint:errcheck
+			}
 			continue
 		}
 
@@ -1422,20 +1795,27 @@ func handleStructSlice(
 	return models, nil
 }
 
-func handleStructPointerSlice(
+func (d *decoder) handleStructPointerSlice(
 	attribute interface{},
 	args []string,
-	fieldValue reflect.Value) (reflect.Value, error) {
+	fieldValue reflect.Value,
+	pointer string) (reflect.Value, error) {
 
 	dataMap := reflect.ValueOf(attribute).Interface().([]interface{})
 	if len(dataMap) == 0 {
 		return reflect.MakeSlice(fieldValue.Type(), 0, 0), nil
 	}
 	models := reflect.New(fieldValue.Type()).Elem()
-	for _, data := range dataMap {
+	for i, data := range dataMap {
 		model := reflect.New(fieldValue.Type().Elem()).Elem()
-		value, err := handleStruct(data, model)
+		elemPointer := jsonPointer(pointer, strconv.Itoa(i))
+		value, err := d.handleStruct(data, model, elemPointer)
 		if err != nil {
+			// Elements that cannot be decoded are skipped, but still reported
+			// when all errors are being collected.
+			if d.opts.collectErrors {
+				d.report(newUnmarshalError(err, elemPointer, "")) //nolint:errcheck
+			}
 			continue
 		}
 
@@ -1444,6 +1824,8 @@ func handleStructPointerSlice(
 	return models, nil
 }
 
+// LidMap maps the local IDs ("lid") of the resources in a request document
+// to the IDs generated for them.
 type LidMap map[string]string
 
 func (l LidMap) Set(k, v string) {
@@ -1460,3 +1842,61 @@ func (l LidMap) Exist(k string) bool {
 	_, exist := l[k]
 	return exist
 }
+
+// lidResolver assigns generated IDs to the resources of a request document
+// that are identified by a local ID, and resolves references to them.
+type lidResolver struct {
+	generator IDGenerator
+	ids       LidMap
+}
+
+// defineLid assigns an ID to the resource n, found at pointer, if it is
+// identified by a local ID. Every resource is defined before any is decoded,
+// so that references resolve regardless of the order resources appear in.
+func (d *decoder) defineLid(n *Node, pointer string) error {
+	if n == nil || n.Lid == "" {
+		return nil
+	}
+
+	if d.lids.ids.Exist(n.Lid) {
+		return d.report(newLidError(ErrDuplicateLid, n.Lid, jsonPointer(pointer, "lid")))
+	}
+
+	if n.ID == "" {
+		id, err := d.lids.generator.Generate()
+		if err != nil {
+			return fmt.Errorf("%s: %w", generateError, err)
+		}
+		n.ID = id
+	}
+	d.lids.ids.Set(n.Lid, n.ID)
+	return nil
+}
+
+// resolveLid gives the resource linkage n, found at loc, the ID of the
+// resource it refers to by local ID. Outside of the *WithLidMap functions the
+// lid itself is used as the ID.
+func (d *decoder) resolveLid(n *Node, loc location) error {
+	if n.ID != "" || n.Lid == "" {
+		return nil
+	}
+
+	if d.lids == nil {
+		n.ID = n.Lid
+		return nil
+	}
+
+	if !d.lids.ids.Exist(n.Lid) {
+		return newLidError(ErrUndefinedLid, n.Lid, jsonPointer(loc.node, "lid"))
+	}
+	n.ID = d.lids.ids.Get(n.Lid)
+	return nil
+}
+
+func newLidError(err error, lid, pointer string) error {
+	return &UnmarshalError{
+		Pointer: pointer,
+		Status:  http.StatusBadRequest,
+		Err:     fmt.Errorf("%w %q", err, lid),
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"net/http"
 	"reflect"
 	"sort"
 	"strconv"
@@ -198,12 +199,14 @@ func TestUnmarshalToStructWithPointerAttr_BadType_bool(t *testing.T) {
 	if err == nil {
 		t.Fatalf("Expected error due to invalid type.")
 	}
-	if err.Error() != expectedErrorMessage {
-		t.Fatalf("Unexpected error message: %s", err.Error())
-	}
-	if _, ok := err.(ErrUnsupportedPtrType); !ok {
+	var ptrErr ErrUnsupportedPtrType
+	if !errors.As(err, &ptrErr) {
 		t.Fatalf("Unexpected error type: %s", reflect.TypeOf(err))
 	}
+	if ptrErr.Error() != expectedErrorMessage {
+		t.Fatalf("Unexpected error message: %s", ptrErr.Error())
+	}
+	assertUnmarshalErrorPointer(t, err, "/data/attributes/name")
 }
 
 func TestUnmarshalToStructWithPointerAttr_BadType_MapPtr(t *testing.T) {
@@ -218,12 +221,14 @@ func TestUnmarshalToStructWithPointerAttr_BadType_MapPtr(t *testing.T) {
 	if err == nil {
 		t.Fatalf("Expected error due to invalid type.")
 	}
-	if err.Error() != expectedErrorMessage {
-		t.Fatalf("Unexpected error message: %s", err.Error())
-	}
-	if _, ok := err.(ErrUnsupportedPtrType); !ok {
+	var ptrErr ErrUnsupportedPtrType
+	if !errors.As(err, &ptrErr) {
 		t.Fatalf("Unexpected error type: %s", reflect.TypeOf(err))
 	}
+	if ptrErr.Error() != expectedErrorMessage {
+		t.Fatalf("Unexpected error message: %s", ptrErr.Error())
+	}
+	assertUnmarshalErrorPointer(t, err, "/data/attributes/name")
 }
 
 func TestUnmarshalToStructWithPointerAttr_BadType_Struct(t *testing.T) {
@@ -239,12 +244,14 @@ func TestUnmarshalToStructWithPointerAttr_BadType_Struct(t *testing.T) {
 	if err == nil {
 		t.Fatalf("Expected error due to invalid type.")
 	}
-	if err.Error() != expectedErrorMessage {
-		t.Fatalf("Unexpected error message: %s", err.Error())
-	}
-	if _, ok := err.(ErrUnsupportedPtrType); !ok {
+	var ptrErr ErrUnsupportedPtrType
+	if !errors.As(err, &ptrErr) {
 		t.Fatalf("Unexpected error type: %s", reflect.TypeOf(err))
 	}
+	if ptrErr.Error() != expectedErrorMessage {
+		t.Fatalf("Unexpected error message: %s", ptrErr.Error())
+	}
+	assertUnmarshalErrorPointer(t, err, "/data/attributes/name")
 }
 
 func TestUnmarshalToStructWithPointerAttr_BadType_IntSlice(t *testing.T) {
@@ -259,12 +266,14 @@ func TestUnmarshalToStructWithPointerAttr_BadType_IntSlice(t *testing.T) {
 	if err == nil {
 		t.Fatalf("Expected error due to invalid type.")
 	}
-	if err.Error() != expectedErrorMessage {
-		t.Fatalf("Unexpected error message: %s", err.Error())
-	}
-	if _, ok := err.(ErrUnsupportedPtrType); !ok {
+	var ptrErr ErrUnsupportedPtrType
+	if !errors.As(err, &ptrErr) {
 		t.Fatalf("Unexpected error type: %s", reflect.TypeOf(err))
 	}
+	if ptrErr.Error() != expectedErrorMessage {
+		t.Fatalf("Unexpected error message: %s", ptrErr.Error())
+	}
+	assertUnmarshalErrorPointer(t, err, "/data/attributes/name")
 }
 
 func TestStringPointerField(t *testing.T) {
@@ -511,6 +520,85 @@ func TestUnmarshalNullableRelationshipsNoRelationships(t *testing.T) {
 	}
 }
 
+func TestUnmarshalNullableRelationships_toManyAndPoly(t *testing.T) {
+	for _, tc := range []struct {
+		desc          string
+		relationships string
+		verification  func(out *WithNullableRelationships) error
+	}{
+		{
+			desc:          "unspecified",
+			relationships: `{"comments": {"links": {"related": "/comments"}}}`,
+			verification: func(out *WithNullableRelationships) error {
+				if out.Comments.IsSpecified() || out.Hero.IsSpecified() || out.Media.IsSpecified() {
+					return errors.New("Expected every relationship to NOT be specified")
+				}
+				return nil
+			},
+		},
+		{
+			desc:          "null",
+			relationships: `{"comments": {"data": null}, "hero": {"data": null}, "media": {"data": null}}`,
+			verification: func(out *WithNullableRelationships) error {
+				if !out.Comments.IsNull() || !out.Hero.IsNull() || !out.Media.IsNull() {
+					return errors.New("Expected every relationship to be explicit null")
+				}
+				return nil
+			},
+		},
+		{
+			desc:          "empty",
+			relationships: `{"comments": {"data": []}, "media": {"data": []}}`,
+			verification: func(out *WithNullableRelationships) error {
+				comments, err := out.Comments.Get()
+				if err != nil || comments == nil || len(comments) != 0 {
+					return fmt.Errorf("Expected comments to be specified and empty, got %v, %v", comments, err)
+				}
+				media, err := out.Media.Get()
+				if err != nil || media == nil || len(media) != 0 {
+					return fmt.Errorf("Expected media to be specified and empty, got %v, %v", media, err)
+				}
+				return nil
+			},
+		},
+		{
+			desc: "values",
+			relationships: `{
+				"comments": {"data": [{"type": "comments", "id": "1"}, {"type": "comments", "id": "2"}]},
+				"hero": {"data": {"type": "images", "id": "i1"}},
+				"media": {"data": [{"type": "videos", "id": "v1"}, {"type": "images", "id": "i2"}]}
+			}`,
+			verification: func(out *WithNullableRelationships) error {
+				comments, err := out.Comments.Get()
+				if err != nil || len(comments) != 2 || comments[1].ID != 2 {
+					return fmt.Errorf("Expected 2 comments, got %v, %v", comments, err)
+				}
+				hero, err := out.Hero.Get()
+				if err != nil || hero.Image == nil || hero.Image.ID != "i1" {
+					return fmt.Errorf("Expected an image hero, got %+v, %v", hero, err)
+				}
+				media, err := out.Media.Get()
+				if err != nil || len(media) != 2 || media[0].Video == nil || media[1].Image == nil {
+					return fmt.Errorf("Expected a video and an image, got %+v, %v", media, err)
+				}
+				return nil
+			},
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			payload := `{"data": {"type": "with-nullable-relationships", "id": "1", "relationships": ` + tc.relationships + `}}`
+
+			out := new(WithNullableRelationships)
+			if err := UnmarshalPayload(strings.NewReader(payload), out); err != nil {
+				t.Fatal(err)
+			}
+			if err := tc.verification(out); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
 func TestMalformedTag(t *testing.T) {
 	out := new(BadModel)
 	err := UnmarshalPayload(samplePayload(), out)
@@ -546,16 +634,16 @@ func TestUnmarshalInvalidJSON_BadType(t *testing.T) {
 			out := new(ModelBadTypes)
 			in := map[string]interface{}{}
 			in[test.Field] = test.BadValue
-			expectedErrorMessage := test.Error.Error()
 
 			err := UnmarshalPayload(samplePayloadWithBadTypes(in), out)
 
 			if err == nil {
 				t.Fatalf("Expected error due to invalid type.")
 			}
-			if err.Error() != expectedErrorMessage {
+			if !errors.Is(err, test.Error) {
 				t.Fatalf("Unexpected error message: %s", err.Error())
 			}
+			assertUnmarshalErrorPointer(t, err, "/data/attributes/"+test.Field)
 		})
 	}
 }
@@ -583,13 +671,15 @@ func TestUnmarshal_nonNumericID(t *testing.T) {
 	in := bytes.NewReader(payload)
 	out := new(Post)
 
-	if err := UnmarshalPayload(in, out); err != ErrBadJSONAPIID {
+	err = UnmarshalPayload(in, out)
+	if !errors.Is(err, ErrBadJSONAPIID) {
 		t.Fatalf(
 			"Was expecting a `%s` error, got `%s`",
 			ErrBadJSONAPIID,
 			err,
 		)
 	}
+	assertUnmarshalErrorPointer(t, err, "/data/id")
 }
 
 func TestUnmarshalSetsAttrs(t *testing.T) {
@@ -1511,9 +1601,701 @@ func TestUnmarshalCustomTypeAttributes_ErrInvalidType(t *testing.T) {
 		t.Fatal("Expected an error unmarshalling the payload due to type mismatch, got none")
 	}
 
-	if err != ErrInvalidType {
+	if !errors.Is(err, ErrInvalidType) {
+		t.Fatalf("Expected error to be %v, was %v", ErrInvalidType, err)
+	}
+}
+
+func TestUnmarshalUnmarshalerAttributes(t *testing.T) {
+	data := map[string]interface{}{
+		"data": map[string]interface{}{
+			"type": "invoices",
+			"id":   "1",
+			"attributes": map[string]interface{}{
+				"total":    "12.50 EUR",
+				"discount": "1.05 EUR",
+				"refund":   nil,
+				"lines":    []string{"10.00 EUR", "2.50 EUR"},
+				"tip":      "0.99 EUR",
+				"origin":   []int{1, 2},
+				"route":    [][]int{{3, 4}, {5, 6}},
+				"dest":     nil,
+			},
+		},
+	}
+	payload, err := json.Marshal(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	invoice := new(Invoice)
+	if err := UnmarshalPayload(bytes.NewReader(payload), invoice); err != nil {
+		t.Fatal(err)
+	}
+
+	if expected, actual := (Money{1250, "EUR"}), invoice.Total; expected != actual {
+		t.Fatalf("Was expecting total to be %v, got %v", expected, actual)
+	}
+	if invoice.Discount == nil || *invoice.Discount != (Money{105, "EUR"}) {
+		t.Fatalf("Was expecting discount to be 1.05 EUR, got %v", invoice.Discount)
+	}
+	if invoice.Refund != nil {
+		t.Fatalf("Was expecting refund to be <nil>, got %v", invoice.Refund)
+	}
+	if expected, actual := []Money{{1000, "EUR"}, {250, "EUR"}}, invoice.Lines; !reflect.DeepEqual(expected, actual) {
+		t.Fatalf("Was expecting lines to be %v, got %v", expected, actual)
+	}
+	if tip, err := invoice.Tip.Get(); err != nil || tip != (Money{99, "EUR"}) {
+		t.Fatalf("Was expecting tip to be 0.99 EUR, got %v (%v)", tip, err)
+	}
+	if expected, actual := (Point{1, 2}), invoice.Origin; expected != actual {
+		t.Fatalf("Was expecting origin to be %v, got %v", expected, actual)
+	}
+	if expected, actual := []*Point{{3, 4}, {5, 6}}, invoice.Route; !reflect.DeepEqual(expected, actual) {
+		t.Fatalf("Was expecting route to be %v, got %v", expected, actual)
+	}
+	if !invoice.Dest.IsNull() {
+		t.Fatalf("Was expecting dest to be null, got %v", invoice.Dest)
+	}
+}
+
+func TestUnmarshalUnmarshalerAttributes_error(t *testing.T) {
+	data := map[string]interface{}{
+		"data": map[string]interface{}{
+			"type": "invoices",
+			"id":   "1",
+			"attributes": map[string]interface{}{
+				"lines": []string{"10.00 EUR", "lots"},
+			},
+		},
+	}
+	payload, err := json.Marshal(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = UnmarshalPayload(bytes.NewReader(payload), new(Invoice))
+	if err == nil {
+		t.Fatal("Expected an error unmarshalling an invalid money value, got none")
+	}
+	assertUnmarshalErrorPointer(t, err, "/data/attributes/lines")
+}
+
+func TestUnmarshalMapAttributes(t *testing.T) {
+	data := map[string]interface{}{
+		"data": map[string]interface{}{
+			"type": "profiles",
+			"id":   "1",
+			"attributes": map[string]interface{}{
+				"settings": map[string]interface{}{"theme": "dark", "size": 12},
+				"limits":   map[string]interface{}{"daily": 10, "monthly": 200},
+				"flags":    map[string]interface{}{"1": true, "2": false},
+				"addresses": map[string]interface{}{
+					"home": map[string]interface{}{"street": "1 Main St", "city": "Springfield"},
+				},
+				"contacts": map[string]interface{}{
+					"work": map[string]interface{}{"street": "2 Side St", "city": "Shelbyville"},
+				},
+				"weights": map[string]interface{}{
+					"a": map[string]interface{}{"x": 0.5},
+				},
+				"history": []interface{}{
+					map[string]interface{}{"event": "created"},
+					map[string]interface{}{"event": "updated"},
+				},
+			},
+		},
+	}
+	payload, err := json.Marshal(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	profile := new(Profile)
+	if err := UnmarshalPayload(bytes.NewReader(payload), profile); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := &Profile{
+		ID:        "1",
+		Settings:  Settings{"theme": "dark", "size": 12.0},
+		Limits:    map[string]int{"daily": 10, "monthly": 200},
+		Flags:     map[int]bool{1: true, 2: false},
+		Addresses: map[string]Address{"home": {Street: "1 Main St", City: "Springfield"}},
+		Contacts:  map[string]*Address{"work": {Street: "2 Side St", City: "Shelbyville"}},
+		Weights:   map[string]map[string]float64{"a": {"x": 0.5}},
+		History:   []map[string]string{{"event": "created"}, {"event": "updated"}},
+	}
+	if !reflect.DeepEqual(expected, profile) {
+		t.Fatalf("Was expecting %+v, got %+v", expected, profile)
+	}
+}
+
+func TestUnmarshalMapAttributes_errorPointer(t *testing.T) {
+	data := map[string]interface{}{
+		"data": map[string]interface{}{
+			"type": "profiles",
+			"id":   "1",
+			"attributes": map[string]interface{}{
+				"weights": map[string]interface{}{
+					"a/b": map[string]interface{}{"x": "heavy"},
+				},
+			},
+		},
+	}
+	payload, err := json.Marshal(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = UnmarshalPayload(bytes.NewReader(payload), new(Profile))
+	if !errors.Is(err, ErrInvalidType) {
 		t.Fatalf("Expected error to be %v, was %v", ErrInvalidType, err)
 	}
+	assertUnmarshalErrorPointer(t, err, "/data/attributes/weights/a~1b/x")
+}
+
+func TestUnmarshalSliceAttributes(t *testing.T) {
+	data := map[string]interface{}{
+		"data": map[string]interface{}{
+			"type": "measurements",
+			"id":   "1",
+			"attributes": map[string]interface{}{
+				"counts": []interface{}{1, 2, 3},
+				"small":  []interface{}{-1, 127},
+				"ratios": []interface{}{0.25, 1.5},
+				"flags":  []interface{}{true, false},
+				"labels": []interface{}{"a", nil, "c"},
+				"taken":  []interface{}{"2016-08-17T08:27:12Z", "2016-08-18T08:27:12Z"},
+				"stamps": []interface{}{1471422432},
+				"grid":   []interface{}{[]interface{}{"a", "b"}, []interface{}{"c"}},
+				"pair":   []interface{}{4, 5},
+				"blob":   "aGVsbG8=",
+			},
+		},
+	}
+	payload, err := json.Marshal(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m := new(Measurements)
+	if err := UnmarshalPayload(bytes.NewReader(payload), m); err != nil {
+		t.Fatal(err)
+	}
+
+	a, c := "a", "c"
+	stamp := time.Unix(1471422432, 0)
+	expected := &Measurements{
+		ID:     "1",
+		Counts: []int{1, 2, 3},
+		Small:  []int8{-1, 127},
+		Ratios: []float64{0.25, 1.5},
+		Flags:  []bool{true, false},
+		Labels: []*string{&a, nil, &c},
+		Taken: []time.Time{
+			time.Date(2016, 8, 17, 8, 27, 12, 0, time.UTC),
+			time.Date(2016, 8, 18, 8, 27, 12, 0, time.UTC),
+		},
+		Stamps: []*time.Time{&stamp},
+		Grid:   [][]string{{"a", "b"}, {"c"}},
+		Pair:   [2]int{4, 5},
+		Blob:   []byte("hello"),
+	}
+	if !reflect.DeepEqual(expected, m) {
+		t.Fatalf("Was expecting %+v, got %+v", expected, m)
+	}
+}
+
+func TestUnmarshalSliceAttributes_errorPointers(t *testing.T) {
+	data := map[string]interface{}{
+		"data": map[string]interface{}{
+			"type": "measurements",
+			"id":   "1",
+			"attributes": map[string]interface{}{
+				"counts": []interface{}{1, "two"},
+				"taken":  []interface{}{"2016-08-17T08:27:12Z", 5},
+				"grid":   []interface{}{[]interface{}{"a", true}},
+			},
+		},
+	}
+	payload, err := json.Marshal(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = UnmarshalPayload(bytes.NewReader(payload), new(Measurements), CollectAllErrors())
+
+	var errs UnmarshalErrors
+	if !errors.As(err, &errs) {
+		t.Fatalf("Expected UnmarshalErrors, got %T: %v", err, err)
+	}
+	pointers := make([]string, len(errs))
+	for i, e := range errs {
+		pointers[i] = e.Pointer
+	}
+	sort.Strings(pointers)
+
+	expected := []string{
+		"/data/attributes/counts/1",
+		"/data/attributes/grid/0/1",
+		"/data/attributes/taken/1",
+	}
+	if !reflect.DeepEqual(expected, pointers) {
+		t.Fatalf("Was expecting errors at %v, got %v", expected, pointers)
+	}
+}
+
+func TestUnmarshalTimeFormats_invalid(t *testing.T) {
+	for _, tc := range []struct {
+		desc       string
+		attributes map[string]interface{}
+		err        error
+		pointer    string
+	}{
+		{
+			desc:       "date_notADate",
+			attributes: map[string]interface{}{"date": "2016-08-17T08:27:12Z"},
+			err:        ErrInvalidTimeLayout,
+			pointer:    "/data/attributes/date",
+		},
+		{
+			desc:       "layout_number",
+			attributes: map[string]interface{}{"layout": 1471422432},
+			err:        ErrInvalidTimeLayout,
+			pointer:    "/data/attributes/layout",
+		},
+		{
+			desc:       "rfc3339nano_notRFC3339",
+			attributes: map[string]interface{}{"rfc3339nano": "yesterday"},
+			err:        ErrInvalidRFC3339,
+			pointer:    "/data/attributes/rfc3339nano",
+		},
+		{
+			desc:       "unixms_fractional",
+			attributes: map[string]interface{}{"unixms": json.Number("1700000000000.7")},
+			err:        ErrNumberOutOfRange,
+			pointer:    "/data/attributes/unixms",
+		},
+		{
+			desc:       "unixnano_outOfRange",
+			attributes: map[string]interface{}{"unixnano": json.Number("1e30")},
+			err:        ErrNumberOutOfRange,
+			pointer:    "/data/attributes/unixnano",
+		},
+		{
+			desc:       "unixms_string",
+			attributes: map[string]interface{}{"slice": []interface{}{"2016-08-17"}},
+			err:        ErrInvalidTime,
+			pointer:    "/data/attributes/slice/0",
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			payload, err := json.Marshal(map[string]interface{}{
+				"data": map[string]interface{}{
+					"type":       "time-formats",
+					"id":         "1",
+					"attributes": tc.attributes,
+				},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			err = UnmarshalPayload(bytes.NewReader(payload), new(TimeFormatsModel))
+			if !errors.Is(err, tc.err) {
+				t.Fatalf("Expected error to be %v, was %v", tc.err, err)
+			}
+			assertUnmarshalErrorPointer(t, err, tc.pointer)
+		})
+	}
+}
+
+func TestTimeLayoutWithComma(t *testing.T) {
+	payload := `{"data": {"type": "comma-time-layouts", "id": "1", "attributes": {"modified": "Wed, 17 Aug 2016 08:27:12 UTC"}}}`
+	if err := UnmarshalPayload(strings.NewReader(payload), new(CommaTimeLayout)); !errors.Is(err, ErrBadJSONAPIStructTag) {
+		t.Fatalf("Was expecting ErrBadJSONAPIStructTag when unmarshaling, got %v", err)
+	}
+
+	model := &CommaTimeLayout{ID: 1, Modified: time.Date(2016, 8, 17, 8, 27, 12, 0, time.UTC)}
+	if err := MarshalPayload(bytes.NewBuffer(nil), model); !errors.Is(err, ErrBadJSONAPIStructTag) {
+		t.Fatalf("Was expecting ErrBadJSONAPIStructTag when marshaling, got %v", err)
+	}
+}
+
+func TestUnmarshalLargeNumbers(t *testing.T) {
+	payload := `{
+		"data": {
+			"type": "big-numbers",
+			"id": "18446744073709551615",
+			"attributes": {
+				"int8": -128,
+				"uint8": 255,
+				"int": 3e2,
+				"int64": 9007199254740993,
+				"uint64": 18446744073709551615,
+				"quoted": "-9223372036854775808",
+				"quoted-ptr": "9007199254740993",
+				"quoted-slice": ["1", 2],
+				"any": {"n": 1.5, "list": [1]}
+			}
+		}
+	}`
+
+	out := new(BigNumbers)
+	if err := UnmarshalPayload(strings.NewReader(payload), out); err != nil {
+		t.Fatal(err)
+	}
+
+	quotedPtr := uint64(9007199254740993)
+	expected := &BigNumbers{
+		ID:          18446744073709551615,
+		Int8:        -128,
+		Uint8:       255,
+		Int:         300,
+		Int64:       9007199254740993,
+		Uint64:      18446744073709551615,
+		Quoted:      -9223372036854775808,
+		QuotedPtr:   &quotedPtr,
+		QuotedSlice: []int64{1, 2},
+		Any:         map[string]interface{}{"n": 1.5, "list": []interface{}{1.0}},
+	}
+	if !reflect.DeepEqual(expected, out) {
+		t.Fatalf("Was expecting %+v, got %+v", expected, out)
+	}
+}
+
+func TestUnmarshalNumbersOutOfRange(t *testing.T) {
+	for _, tc := range []struct {
+		desc       string
+		attributes string
+		pointer    string
+	}{
+		{"int8_overflow", `{"int8": 300}`, "/data/attributes/int8"},
+		{"uint8_negative", `{"uint8": -1}`, "/data/attributes/uint8"},
+		{"int_fraction", `{"int": 1.5}`, "/data/attributes/int"},
+		{"int64_overflow", `{"int64": 9223372036854775808}`, "/data/attributes/int64"},
+		{"uint64_overflow", `{"uint64": 18446744073709551616}`, "/data/attributes/uint64"},
+		{"float32_overflow", `{"float32": 1e39}`, "/data/attributes/float32"},
+		{"quoted_notANumber", `{"quoted": "twelve"}`, "/data/attributes/quoted"},
+		{"quoted_sliceElement", `{"quoted-slice": ["1", "1.5"]}`, "/data/attributes/quoted-slice/1"},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			payload := `{"data": {"type": "big-numbers", "id": "1", "attributes": ` + tc.attributes + `}}`
+
+			err := UnmarshalPayload(strings.NewReader(payload), new(BigNumbers))
+			if !errors.Is(err, ErrNumberOutOfRange) {
+				t.Fatalf("Expected error to be %v, was %v", ErrNumberOutOfRange, err)
+			}
+			assertUnmarshalErrorPointer(t, err, tc.pointer)
+		})
+	}
+}
+
+func TestUnmarshalDocument(t *testing.T) {
+	payload := `{
+		"jsonapi": {"version": "1.1", "ext": ["https://jsonapi.org/ext/atomic"], "profile": ["https://example.com/profile"]},
+		"links": {"self": "http://example.com/posts/1", "related": {"href": "http://example.com/blogs/1", "meta": {"count": 3}}},
+		"meta": {"requested_at": "2026-01-02"},
+		"data": {"type": "posts", "id": "1", "relationships": {"latest_comment": {"data": {"type": "comments", "id": "10"}}}},
+		"included": [{"type": "comments", "id": "10", "attributes": {"body": "Hello"}}]
+	}`
+
+	post := new(Post)
+	doc, err := UnmarshalDocument(strings.NewReader(payload), post)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if post.ID != 1 || post.LatestComment == nil || post.LatestComment.Body != "Hello" {
+		t.Fatalf("Was expecting the model to be decoded, got %+v", post)
+	}
+	if (*doc.Links)["self"] != "http://example.com/posts/1" {
+		t.Fatalf("Was expecting the self link, got %v", (*doc.Links)["self"])
+	}
+	related, ok := (*doc.Links)["related"].(Link)
+	if !ok || related.Href != "http://example.com/blogs/1" || related.Meta["count"] != float64(3) {
+		t.Fatalf("Was expecting the related link object, got %#v", (*doc.Links)["related"])
+	}
+	if (*doc.Meta)["requested_at"] != "2026-01-02" {
+		t.Fatalf("Was expecting the document meta, got %v", doc.Meta)
+	}
+	if doc.JSONAPI == nil || doc.JSONAPI.Version != "1.1" ||
+		!reflect.DeepEqual(doc.JSONAPI.Ext, []string{"https://jsonapi.org/ext/atomic"}) ||
+		!reflect.DeepEqual(doc.JSONAPI.Profile, []string{"https://example.com/profile"}) {
+		t.Fatalf("Was expecting the jsonapi object, got %+v", doc.JSONAPI)
+	}
+	if len(doc.Included) != 1 || doc.Included[0].ID != "10" {
+		t.Fatalf("Was expecting the included resources, got %v", doc.Included)
+	}
+}
+
+func TestUnmarshalManyDocument(t *testing.T) {
+	payload := `{
+		"links": {"next": "http://example.com/posts?page[number]=2"},
+		"meta": {"total": 12},
+		"data": [{"type": "posts", "id": "1"}, {"type": "posts", "id": "2"}]
+	}`
+
+	models, doc, err := UnmarshalManyDocument(strings.NewReader(payload), reflect.TypeOf(new(Post)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(models) != 2 {
+		t.Fatalf("Was expecting 2 posts, got %d", len(models))
+	}
+	if (*doc.Links)["next"] != "http://example.com/posts?page[number]=2" {
+		t.Fatalf("Was expecting the next link, got %v", doc.Links)
+	}
+	if (*doc.Meta)["total"] != float64(12) {
+		t.Fatalf("Was expecting the total as a float64, got %#v", (*doc.Meta)["total"])
+	}
+	if doc.JSONAPI != nil || doc.Included != nil {
+		t.Fatalf("Was expecting no jsonapi object or included resources, got %+v", doc)
+	}
+}
+
+func TestUnmarshalRelationshipLinksAndMeta(t *testing.T) {
+	payload := `{
+		"data": {
+			"type": "articles",
+			"id": "1",
+			"relationships": {
+				"comments": {
+					"data": [{"type": "comments", "id": "10"}],
+					"links": {"related": "http://example.com/articles/1/comments"},
+					"meta": {"count": 42}
+				},
+				"author": {
+					"links": {"self": {"href": "http://example.com/articles/1/relationships/author", "meta": {"editable": true}}}
+				}
+			}
+		}
+	}`
+
+	article := new(Article)
+	if err := UnmarshalPayload(strings.NewReader(payload), article, DisallowUnknownMembers()); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(article.Comments) != 1 {
+		t.Fatalf("Was expecting the comments to be decoded, got %+v", article.Comments)
+	}
+	if article.CommentsMeta["count"] != float64(42) {
+		t.Fatalf("Was expecting the comment count, got %#v", article.CommentsMeta)
+	}
+	if article.CommentsLinks == nil || (*article.CommentsLinks)["related"] != "http://example.com/articles/1/comments" {
+		t.Fatalf("Was expecting the related link, got %v", article.CommentsLinks)
+	}
+
+	self, ok := article.AuthorLinks["self"].(Link)
+	if !ok || self.Href != "http://example.com/articles/1/relationships/author" || self.Meta["editable"] != true {
+		t.Fatalf("Was expecting the self link object, got %#v", article.AuthorLinks)
+	}
+	if article.Author != nil || article.AuthorMeta != nil {
+		t.Fatalf("Was expecting no author or author meta, got %+v, %v", article.Author, article.AuthorMeta)
+	}
+
+	// Relationship links and meta fields are left out when marshaling
+	if err := MarshalPayload(bytes.NewBuffer(nil), article); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// friendsPayload is a compound document in which users refer to one another
+// in a cycle: alice -> bob -> alice, and bob -> carol -> bob.
+const friendsPayload = `{
+	"data": {
+		"type": "users", "id": "1", "attributes": {"name": "Alice"},
+		"relationships": {"best_friend": {"data": {"type": "users", "id": "2"}}}
+	},
+	"included": [
+		{
+			"type": "users", "id": "2", "attributes": {"name": "Bob"},
+			"relationships": {
+				"best_friend": {"data": {"type": "users", "id": "1"}},
+				"friends": {"data": [{"type": "users", "id": "1"}, {"type": "users", "id": "3"}]}
+			}
+		},
+		{
+			"type": "users", "id": "3", "attributes": {"name": "Carol"},
+			"relationships": {"best_friend": {"data": {"type": "users", "id": "2"}}}
+		}
+	]
+}`
+
+func TestUnmarshalPayload_relationshipCycles(t *testing.T) {
+	alice := new(User)
+	if err := UnmarshalPayload(strings.NewReader(friendsPayload), alice); err != nil {
+		t.Fatal(err)
+	}
+
+	bob := alice.BestFriend
+	if bob == nil || bob.Name != "Bob" {
+		t.Fatalf("Was expecting bob to be decoded from included, got %+v", bob)
+	}
+	if bob.BestFriend != alice {
+		t.Fatal("Was expecting bob's best friend to be the primary data model")
+	}
+	if len(bob.Friends) != 2 || bob.Friends[0] != alice {
+		t.Fatalf("Was expecting alice among bob's friends, got %+v", bob.Friends)
+	}
+
+	carol := bob.Friends[1]
+	if carol.Name != "Carol" || carol.BestFriend != bob {
+		t.Fatalf("Was expecting carol to refer back to bob, got %+v", carol)
+	}
+}
+
+func TestUnmarshalPayload_maxIncludeDepth(t *testing.T) {
+	alice := new(User)
+	err := UnmarshalPayload(strings.NewReader(friendsPayload), alice, MaxIncludeDepth(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bob := alice.BestFriend
+	if bob.Name != "Bob" {
+		t.Fatalf("Was expecting bob to be decoded from included, got %+v", bob)
+	}
+	carol := bob.Friends[1]
+	if carol.ID != 3 || carol.Name != "" || carol.BestFriend != nil {
+		t.Fatalf("Was expecting carol to be decoded from linkage only, got %+v", carol)
+	}
+
+	alice = new(User)
+	err = UnmarshalPayload(strings.NewReader(friendsPayload), alice, MaxIncludeDepth(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bob := alice.BestFriend; bob.ID != 2 || bob.Name != "" {
+		t.Fatalf("Was expecting bob to be decoded from linkage only, got %+v", bob)
+	}
+}
+
+// sequenceGenerator generates the IDs "100", "101", ...
+type sequenceGenerator struct {
+	next int
+}
+
+func (g *sequenceGenerator) Generate() (string, error) {
+	id := strconv.Itoa(100 + g.next)
+	g.next++
+	return id, nil
+}
+
+func TestUnmarshalPayloadWithLidMap(t *testing.T) {
+	// The comment is referred to before the included resource defining it
+	payload := `{
+		"data": {
+			"type": "posts",
+			"lid": "post",
+			"attributes": {"title": "New"},
+			"relationships": {
+				"latest_comment": {"data": {"type": "comments", "lid": "comment"}}
+			}
+		},
+		"included": [
+			{"type": "comments", "lid": "comment", "attributes": {"body": "First!"}}
+		]
+	}`
+
+	post := new(Post)
+	lids, err := UnmarshalPayloadWithLidMap(strings.NewReader(payload), post, &sequenceGenerator{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if expected := map[string]string{"post": "100", "comment": "101"}; !reflect.DeepEqual(expected, lids) {
+		t.Fatalf("Was expecting lids %v, got %v", expected, lids)
+	}
+	if post.ID != 100 {
+		t.Fatalf("Was expecting the post to be given ID 100, got %d", post.ID)
+	}
+	if post.LatestComment == nil || post.LatestComment.ID != 101 || post.LatestComment.Body != "First!" {
+		t.Fatalf("Was expecting the latest comment to be resolved by lid, got %+v", post.LatestComment)
+	}
+}
+
+func TestUnmarshalManyPayloadWithLidMap(t *testing.T) {
+	// The first post refers to the second, and to an included comment, by lid
+	payload := `{
+		"data": [
+			{
+				"type": "posts",
+				"lid": "a",
+				"relationships": {
+					"comments": {"data": [
+						{"type": "comments", "lid": "c"},
+						{"type": "comments", "id": "7"}
+					]}
+				}
+			},
+			{"type": "posts", "lid": "b"},
+			{"type": "posts", "id": "3"}
+		],
+		"included": [
+			{"type": "comments", "lid": "c", "attributes": {"body": "Shared"}}
+		]
+	}`
+
+	models, lids, err := UnmarshalManyPayloadWithLidMap(strings.NewReader(payload), reflect.TypeOf(new(Post)), &sequenceGenerator{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if expected := map[string]string{"a": "100", "b": "101", "c": "102"}; !reflect.DeepEqual(expected, lids) {
+		t.Fatalf("Was expecting lids %v, got %v", expected, lids)
+	}
+
+	posts := make([]*Post, len(models))
+	for i, m := range models {
+		posts[i] = m.(*Post)
+	}
+	if posts[0].ID != 100 || posts[1].ID != 101 || posts[2].ID != 3 {
+		t.Fatalf("Unexpected post IDs %d, %d, %d", posts[0].ID, posts[1].ID, posts[2].ID)
+	}
+	comments := posts[0].Comments
+	if len(comments) != 2 || comments[0].ID != 102 || comments[0].Body != "Shared" || comments[1].ID != 7 {
+		t.Fatalf("Unexpected comments %+v", comments)
+	}
+}
+
+func TestUnmarshalManyPayloadWithLidMap_invalidLids(t *testing.T) {
+	payload := `{
+		"data": [
+			{"type": "posts", "lid": "a"},
+			{"type": "posts", "lid": "a"},
+			{
+				"type": "posts",
+				"lid": "b",
+				"relationships": {
+					"latest_comment": {"data": {"type": "comments", "lid": "missing"}}
+				}
+			}
+		]
+	}`
+
+	_, _, err := UnmarshalManyPayloadWithLidMap(strings.NewReader(payload), reflect.TypeOf(new(Post)), &sequenceGenerator{}, CollectAllErrors())
+
+	var errs UnmarshalErrors
+	if !errors.As(err, &errs) || len(errs) != 2 {
+		t.Fatalf("Was expecting two UnmarshalErrors, got %v", err)
+	}
+	if !errors.Is(errs[0], ErrDuplicateLid) || errs[0].Pointer != "/data/1/lid" {
+		t.Fatalf("Was expecting a duplicate lid at /data/1/lid, got %v", errs[0])
+	}
+	if !errors.Is(errs[1], ErrUndefinedLid) || errs[1].Pointer != "/data/2/relationships/latest_comment/data/lid" {
+		t.Fatalf("Was expecting an undefined lid at the linkage, got %v", errs[1])
+	}
+}
+
+func TestUnmarshalPayloadWithLidMap_nilGenerator(t *testing.T) {
+	_, err := UnmarshalPayloadWithLidMap(strings.NewReader(`{"data": null}`), new(Post), nil)
+	if err == nil {
+		t.Fatal("Was expecting an error for a nil generator")
+	}
 }
 
 func samplePayloadWithoutIncluded() map[string]interface{} {
@@ -2101,3 +2883,420 @@ func TestUnmarshalNullableAttributeOmitted(t *testing.T) {
 		t.Fatalf("expected unspecified, got boolean")
 	}
 }
+
+func TestUnmarshalOptional(t *testing.T) {
+	payload := `{"data": {"type": "with-optionals", "id": "1", "attributes": {
+		"name": null,
+		"born": "2016-08-17T08:27:12Z"
+	}}}`
+
+	out := new(WithOptionals)
+	if err := UnmarshalPayload(strings.NewReader(payload), out); err != nil {
+		t.Fatal(err)
+	}
+
+	if !out.Name.IsSpecified() || !out.Name.IsNull() {
+		t.Fatal("Expected Name to be specified and explicit null")
+	}
+	if out.Count.IsSpecified() {
+		t.Fatal("Expected Count to NOT be specified")
+	}
+	born, err := out.Born.Get()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := time.Date(2016, 8, 17, 8, 27, 12, 0, time.UTC); !born.Equal(expected) {
+		t.Fatalf("Expected Born to be %v, got %v", expected, born)
+	}
+}
+
+func TestUnmarshalOptional_invalid(t *testing.T) {
+	payload := `{"data": {"type": "with-optionals", "id": "1", "attributes": {"count": "many"}}}`
+
+	err := UnmarshalPayload(strings.NewReader(payload), new(WithOptionals))
+	assertUnmarshalErrorPointer(t, err, "/data/attributes/count")
+}
+
+func assertUnmarshalErrorPointer(t *testing.T, err error, pointer string) {
+	t.Helper()
+
+	var unmarshalErr *UnmarshalError
+	if !errors.As(err, &unmarshalErr) {
+		t.Fatalf("expected an *UnmarshalError, got %T: %v", err, err)
+	}
+	if unmarshalErr.Pointer != pointer {
+		t.Fatalf("expected error pointer %q, got %q", pointer, unmarshalErr.Pointer)
+	}
+}
+
+func TestUnmarshalPayload_errorPointers(t *testing.T) {
+	for _, tc := range []struct {
+		desc    string
+		model   interface{}
+		payload string
+		pointer string
+		field   string
+		status  int
+		target  error
+	}{
+		{
+			desc:    "attribute",
+			model:   new(Blog),
+			payload: `{"data": {"type": "blogs", "id": "1", "attributes": {"created_at": "yesterday"}}}`,
+			pointer: "/data/attributes/created_at",
+			field:   "CreatedAt",
+			status:  http.StatusBadRequest,
+			target:  ErrInvalidTime,
+		},
+		{
+			desc:    "pointer attribute",
+			model:   new(WithPointer),
+			payload: `{"data": {"type": "with-pointers", "attributes": {"is-active": "yes"}}}`,
+			pointer: "/data/attributes/is-active",
+			field:   "IsActive",
+			status:  http.StatusBadRequest,
+		},
+		{
+			desc:    "nested struct attribute",
+			model:   new(Company),
+			payload: `{"data": {"type": "companies", "id": "1", "attributes": {"boss": {"firstname": 5}}}}`,
+			pointer: "/data/attributes/boss/firstname",
+			field:   "Firstname",
+			status:  http.StatusBadRequest,
+			target:  ErrUnknownFieldNumberType,
+		},
+		{
+			desc:    "primary type",
+			model:   new(Blog),
+			payload: `{"data": {"type": "posts", "id": "1"}}`,
+			pointer: "/data/type",
+			field:   "ID",
+			status:  http.StatusConflict,
+		},
+		{
+			desc:    "primary id",
+			model:   new(Blog),
+			payload: `{"data": {"type": "blogs", "id": "one"}}`,
+			pointer: "/data/id",
+			field:   "ID",
+			status:  http.StatusBadRequest,
+			target:  ErrBadJSONAPIID,
+		},
+		{
+			desc:    "to-one linkage type",
+			model:   new(Blog),
+			payload: `{"data": {"type": "blogs", "id": "1", "relationships": {"current_post": {"data": {"type": "comments", "id": "1"}}}}}`,
+			pointer: "/data/relationships/current_post/data/type",
+			field:   "CurrentPost",
+			status:  http.StatusConflict,
+		},
+		{
+			desc:    "to-many linkage type",
+			model:   new(Blog),
+			payload: `{"data": {"type": "blogs", "id": "1", "relationships": {"posts": {"data": [{"type": "posts", "id": "1"}, {"type": "comments", "id": "2"}]}}}}`,
+			pointer: "/data/relationships/posts/data/1/type",
+			field:   "Posts",
+			status:  http.StatusConflict,
+		},
+		{
+			desc:    "embedded related resource",
+			model:   new(Blog),
+			payload: `{"data": {"type": "blogs", "id": "1", "relationships": {"posts": {"data": [{"type": "posts", "attributes": {"title": 5}}]}}}}`,
+			pointer: "/data/relationships/posts/data/0/attributes/title",
+			field:   "Title",
+			status:  http.StatusBadRequest,
+			target:  ErrUnknownFieldNumberType,
+		},
+		{
+			desc:  "included resource",
+			model: new(Blog),
+			payload: `{
+				"data": {"type": "blogs", "id": "1", "relationships": {"current_post": {"data": {"type": "posts", "id": "2"}}}},
+				"included": [
+					{"type": "comments", "id": "1"},
+					{"type": "posts", "id": "2", "attributes": {"title": 5}}
+				]
+			}`,
+			pointer: "/included/1/attributes/title",
+			field:   "Title",
+			status:  http.StatusBadRequest,
+			target:  ErrUnknownFieldNumberType,
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			err := UnmarshalPayload(strings.NewReader(tc.payload), tc.model)
+
+			var unmarshalErr *UnmarshalError
+			if !errors.As(err, &unmarshalErr) {
+				t.Fatalf("expected an *UnmarshalError, got %T: %v", err, err)
+			}
+			if unmarshalErr.Pointer != tc.pointer {
+				t.Errorf("expected pointer %q, got %q", tc.pointer, unmarshalErr.Pointer)
+			}
+			if unmarshalErr.Field != tc.field {
+				t.Errorf("expected field %q, got %q", tc.field, unmarshalErr.Field)
+			}
+			if unmarshalErr.Status != tc.status {
+				t.Errorf("expected status %d, got %d", tc.status, unmarshalErr.Status)
+			}
+			if tc.target != nil && !errors.Is(err, tc.target) {
+				t.Errorf("expected %v to wrap %v", err, tc.target)
+			}
+		})
+	}
+}
+
+func TestUnmarshalManyPayload_errorPointer(t *testing.T) {
+	payload := `{"data": [
+		{"type": "blogs", "id": "1", "attributes": {"title": "ok"}},
+		{"type": "blogs", "id": "2", "attributes": {"title": 5}}
+	]}`
+
+	_, err := UnmarshalManyPayload(strings.NewReader(payload), reflect.TypeOf(new(Blog)))
+	assertUnmarshalErrorPointer(t, err, "/data/1/attributes/title")
+}
+
+func TestUnmarshalPayload_collectAllErrors(t *testing.T) {
+	payload := `{
+		"data": {
+			"type": "blogs",
+			"id": "1",
+			"attributes": {
+				"title": 5,
+				"created_at": "yesterday",
+				"view_count": 1000,
+				"current_post_id": "two"
+			},
+			"relationships": {
+				"posts": {"data": [{"type": "comments", "id": "1"}, {"type": "posts", "id": "2"}]},
+				"current_post": {"data": {"type": "posts", "id": "3"}}
+			}
+		},
+		"included": [
+			{"type": "posts", "id": "2", "attributes": {"title": "Two"}},
+			{"type": "posts", "id": "3", "attributes": {"title": false, "body": "Three"}}
+		]
+	}`
+
+	out := new(Blog)
+	err := UnmarshalPayload(strings.NewReader(payload), out, CollectAllErrors())
+
+	var errs UnmarshalErrors
+	if !errors.As(err, &errs) {
+		t.Fatalf("expected UnmarshalErrors, got %T: %v", err, err)
+	}
+
+	expected := []string{
+		"/data/attributes/title",
+		"/data/relationships/posts/data/0/type",
+		"/included/1/attributes/title",
+		"/data/attributes/current_post_id",
+		"/data/attributes/created_at",
+	}
+	if len(errs) != len(expected) {
+		t.Fatalf("expected %d errors, got %d: %v", len(expected), len(errs), errs)
+	}
+	for i, pointer := range expected {
+		if errs[i].Pointer != pointer {
+			t.Errorf("expected error %d to point at %q, got %q", i, pointer, errs[i].Pointer)
+		}
+	}
+
+	if out.ID != 1 || out.ViewCount != 1000 {
+		t.Errorf("expected valid attributes to be populated, got %+v", out)
+	}
+	if len(out.Posts) != 1 || out.Posts[0].Title != "Two" {
+		t.Errorf("expected the valid post to be populated, got %+v", out.Posts)
+	}
+	if out.CurrentPost == nil || out.CurrentPost.Body != "Three" {
+		t.Errorf("expected the current post to be partially populated, got %+v", out.CurrentPost)
+	}
+
+	objs := errs.ErrorObjects()
+	if len(objs) != len(errs) || objs[1].Status != "409" || objs[0].Source.Pointer != expected[0] {
+		t.Errorf("unexpected error objects: %+v", objs)
+	}
+}
+
+func TestUnmarshalPayload_collectAllErrorsNestedSlices(t *testing.T) {
+	payload := `{
+		"data": {
+			"type": "companies",
+			"id": "1",
+			"attributes": {
+				"name": "ACME",
+				"teams": ["not a team", {"name": "Core", "members": [{"firstname": "Ada", "age": "old"}]}]
+			}
+		}
+	}`
+
+	out := new(Company)
+	err := UnmarshalPayload(strings.NewReader(payload), out, CollectAllErrors())
+
+	var errs UnmarshalErrors
+	if !errors.As(err, &errs) {
+		t.Fatalf("expected UnmarshalErrors, got %T: %v", err, err)
+	}
+
+	expected := []string{
+		"/data/attributes/teams/0",
+		"/data/attributes/teams/1/members/0/age",
+	}
+	if len(errs) != len(expected) {
+		t.Fatalf("expected %d errors, got %d: %v", len(expected), len(errs), errs)
+	}
+	for i, pointer := range expected {
+		if errs[i].Pointer != pointer {
+			t.Errorf("expected error %d to point at %q, got %q", i, pointer, errs[i].Pointer)
+		}
+	}
+
+	if out.Name != "ACME" || len(out.Teams) != 1 || out.Teams[0].Members[0].Firstname != "Ada" {
+		t.Errorf("expected valid members to be populated, got %+v", out)
+	}
+}
+
+func TestUnmarshalManyPayload_collectAllErrors(t *testing.T) {
+	payload := `{"data": [
+		{"type": "blogs", "id": "1", "attributes": {"title": 1}},
+		{"type": "blogs", "id": "2", "attributes": {"title": "ok"}},
+		{"type": "posts", "id": "3", "attributes": {"title": "ok"}}
+	]}`
+
+	models, err := UnmarshalManyPayload(strings.NewReader(payload), reflect.TypeOf(new(Blog)), CollectAllErrors())
+
+	var errs UnmarshalErrors
+	if !errors.As(err, &errs) {
+		t.Fatalf("expected UnmarshalErrors, got %T: %v", err, err)
+	}
+	if len(errs) != 2 || errs[0].Pointer != "/data/0/attributes/title" || errs[1].Pointer != "/data/2/type" {
+		t.Fatalf("unexpected errors: %v", errs)
+	}
+	if len(models) != 3 || models[1].(*Blog).Title != "ok" || models[2].(*Blog).Title != "ok" {
+		t.Fatalf("expected every model to be returned, got %+v", models)
+	}
+}
+
+func TestUnmarshalPayload_disallowUnknownMembers(t *testing.T) {
+	for _, tc := range []struct {
+		desc    string
+		model   interface{}
+		payload string
+		pointer string
+		target  error
+	}{
+		{
+			desc:    "top-level member",
+			model:   new(Blog),
+			payload: `{"data": {"type": "blogs", "id": "1"}, "errors": []}`,
+			pointer: "/errors",
+			target:  ErrUnknownMember,
+		},
+		{
+			desc:    "resource object member",
+			model:   new(Blog),
+			payload: `{"data": {"type": "blogs", "id": "1", "attribute": {"title": "Hi"}}}`,
+			pointer: "/data/attribute",
+			target:  ErrUnknownMember,
+		},
+		{
+			desc:    "included resource object member",
+			model:   new(Blog),
+			payload: `{"data": {"type": "blogs", "id": "1"}, "included": [{"type": "posts", "id": "1", "attrs": {}}]}`,
+			pointer: "/included/0/attrs",
+			target:  ErrUnknownMember,
+		},
+		{
+			desc:    "attribute",
+			model:   new(Blog),
+			payload: `{"data": {"type": "blogs", "id": "1", "attributes": {"title": "Hi", "titel": "Hi"}}}`,
+			pointer: "/data/attributes/titel",
+			target:  ErrUnknownMember,
+		},
+		{
+			desc:    "nested struct attribute",
+			model:   new(Company),
+			payload: `{"data": {"type": "companies", "id": "1", "attributes": {"boss": {"firstname": "Ada", "nickname": "A"}}}}`,
+			pointer: "/data/attributes/boss/nickname",
+			target:  ErrUnknownMember,
+		},
+		{
+			desc:    "relationship",
+			model:   new(Blog),
+			payload: `{"data": {"type": "blogs", "id": "1", "relationships": {"author": {"data": null}}}}`,
+			pointer: "/data/relationships/author",
+			target:  ErrUnknownMember,
+		},
+		{
+			desc:    "relationship object member",
+			model:   new(Blog),
+			payload: `{"data": {"type": "blogs", "id": "1", "relationships": {"current_post": {"data": null, "included": true}}}}`,
+			pointer: "/data/relationships/current_post/included",
+			target:  ErrUnknownMember,
+		},
+		{
+			desc:    "related resource attribute",
+			model:   new(Blog),
+			payload: `{"data": {"type": "blogs", "id": "1", "relationships": {"current_post": {"data": {"type": "posts", "id": "2"}}}}, "included": [{"type": "posts", "id": "2", "attributes": {"subject": "Hi"}}]}`,
+			pointer: "/included/0/attributes/subject",
+			target:  ErrUnknownMember,
+		},
+		{
+			desc:    "polymorphic relationship type",
+			model:   new(BlogPostWithPoly),
+			payload: `{"data": {"type": "blogs", "id": "1", "relationships": {"hero-media": {"data": {"type": "audio", "id": "2"}}}}}`,
+			pointer: "/data/relationships/hero-media/data/type",
+			target:  ErrUnknownType,
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			err := UnmarshalPayload(strings.NewReader(tc.payload), tc.model, DisallowUnknownMembers())
+			if !errors.Is(err, tc.target) {
+				t.Fatalf("expected %v, got %v", tc.target, err)
+			}
+			assertUnmarshalErrorPointer(t, err, tc.pointer)
+		})
+	}
+}
+
+func TestUnmarshalPayload_disallowUnknownMembersAcceptsKnownMembers(t *testing.T) {
+	out := new(Blog)
+	in := samplePayload()
+
+	if err := UnmarshalPayload(in, out, DisallowUnknownMembers()); err != nil {
+		t.Fatal(err)
+	}
+
+	// Without the option unknown members are ignored
+	payload := `{"data": {"type": "blogs", "id": "1", "attributes": {"titel": "Hi"}}, "foo": 1}`
+	if err := UnmarshalPayload(strings.NewReader(payload), new(Blog)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestUnmarshalManyPayload_disallowUnknownMembersCollectAll(t *testing.T) {
+	payload := `{
+		"data": [
+			{"type": "blogs", "id": "1", "attributes": {"titel": "Hi"}},
+			{"type": "blogs", "id": "2", "attributes": {"title": "Hi"}, "relationships": {"comments": {"data": []}}}
+		],
+		"foo": 1
+	}`
+
+	_, err := UnmarshalManyPayload(strings.NewReader(payload), reflect.TypeOf(new(Blog)), DisallowUnknownMembers(), CollectAllErrors())
+
+	var errs UnmarshalErrors
+	if !errors.As(err, &errs) {
+		t.Fatalf("expected UnmarshalErrors, got %T: %v", err, err)
+	}
+
+	expected := []string{"/foo", "/data/0/attributes/titel", "/data/1/relationships/comments"}
+	if len(errs) != len(expected) {
+		t.Fatalf("expected %d errors, got %d: %v", len(expected), len(errs), errs)
+	}
+	for i, pointer := range expected {
+		if errs[i].Pointer != pointer {
+			t.Errorf("expected error %d to point at %q, got %q", i, pointer, errs[i].Pointer)
+		}
+	}
+}
@@ -1,6 +1,7 @@
 package jsonapi
 
 import (
+	"encoding"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -63,8 +64,8 @@ var (
 //				 http.Error(w, err.Error(), http.StatusInternalServerError)
 //			 }
 //		 }
-func MarshalPayload(w io.Writer, models interface{}) error {
-	payload, err := Marshal(models)
+func MarshalPayload(w io.Writer, models interface{}, opts ...MarshalOption) error {
+	payload, err := Marshal(models, opts...)
 	if err != nil {
 		return err
 	}
@@ -75,7 +76,7 @@ func MarshalPayload(w io.Writer, models interface{}) error {
 // Marshal does the same as MarshalPayload except it just returns the payload
 // and doesn't write out results. Useful if you use your own JSON rendering
 // library.
-func Marshal(models interface{}) (Payloader, error) {
+func Marshal(models interface{}, opts ...MarshalOption) (Payloader, error) {
 	switch vals := reflect.ValueOf(models); vals.Kind() {
 	case reflect.Slice:
 		length := vals.Len()
@@ -98,7 +99,7 @@ func Marshal(models interface{}) (Payloader, error) {
 			return nil, err
 		}
 
-		payload, err := marshalMany(m)
+		payload, err := marshalMany(m, opts)
 		if err != nil {
 			return nil, err
 		}
@@ -121,7 +122,7 @@ func Marshal(models interface{}) (Payloader, error) {
 		if reflect.Indirect(vals).Kind() != reflect.Struct {
 			return nil, ErrUnexpectedType
 		}
-		return marshalOne(models)
+		return marshalOne(models, opts)
 	default:
 		return nil, ErrUnexpectedType
 	}
@@ -134,8 +135,8 @@ func Marshal(models interface{}) (Payloader, error) {
 //
 // models interface{} should be either a struct pointer or a slice of struct
 // pointers.
-func MarshalPayloadWithoutIncluded(w io.Writer, model interface{}) error {
-	payload, err := Marshal(model)
+func MarshalPayloadWithoutIncluded(w io.Writer, model interface{}, opts ...MarshalOption) error {
+	payload, err := Marshal(model, opts...)
 	if err != nil {
 		return err
 	}
@@ -147,16 +148,16 @@ func MarshalPayloadWithoutIncluded(w io.Writer, model interface{}) error {
 // marshalOne does the same as MarshalOnePayload except it just returns the
 // payload and doesn't write out results. Useful is you use your JSON rendering
 // library.
-func marshalOne(model interface{}) (*OnePayload, error) {
-	included := make(map[string]*Node)
+func marshalOne(model interface{}, opts []MarshalOption) (*OnePayload, error) {
+	e := newEncoder(true, opts)
 
-	rootNode, err := visitModelNode(model, &included, true)
+	rootNode, err := e.visitModelNode(model)
 	if err != nil {
 		return nil, err
 	}
 	payload := &OnePayload{Data: rootNode}
 
-	payload.Included = nodeMapValues(&included)
+	payload.Included = nodeMapValues(&e.included)
 
 	return payload, nil
 }
@@ -164,20 +165,28 @@ func marshalOne(model interface{}) (*OnePayload, error) {
 // marshalMany does the same as MarshalManyPayload except it just returns the
 // payload and doesn't write out results. Useful is you use your JSON rendering
 // library.
-func marshalMany(models []interface{}) (*ManyPayload, error) {
+func marshalMany(models []interface{}, opts []MarshalOption) (*ManyPayload, error) {
 	payload := &ManyPayload{
 		Data: []*Node{},
 	}
-	included := map[string]*Node{}
+	e := newEncoder(true, opts)
 
 	for _, model := range models {
-		node, err := visitModelNode(model, &included, true)
+		node, err := e.visitModelNode(model)
 		if err != nil {
 			return nil, err
 		}
 		payload.Data = append(payload.Data, node)
 	}
-	payload.Included = nodeMapValues(&included)
+
+	// Resources related to one another within "data" are not repeated in
+	// "included"
+	for _, node := range payload.Data {
+		if node != nil && node.ID != "" {
+			delete(e.included, resourceKey(node))
+		}
+	}
+	payload.Included = nodeMapValues(&e.included)
 
 	return payload, nil
 }
@@ -198,7 +207,7 @@ func marshalMany(models []interface{}) (*ManyPayload, error) {
 //
 // model interface{} should be a pointer to a struct.
 func MarshalOnePayloadEmbedded(w io.Writer, model interface{}) error {
-	rootNode, err := visitModelNode(model, nil, false)
+	rootNode, err := newEncoder(false, nil).visitModelNode(model)
 	if err != nil {
 		return err
 	}
@@ -239,27 +248,65 @@ func selectChoiceTypeStructField(structValue reflect.Value) (reflect.Value, erro
 // hasJSONAPIAnnotations returns true if any of the fields of a struct type t
 // has a jsonapi annotation. This function will panic if t is not a struct type.
 func hasJSONAPIAnnotations(t reflect.Type) bool {
-	for i := 0; i < t.NumField(); i++ {
-		tag := t.Field(i).Tag.Get(annotationJSONAPI)
-		if tag != "" {
-			return true
-		}
+	return len(modelFields(t)) > 0
+}
+
+var (
+	jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+// encodesItself reports whether t, or the element type of a pointer, slice
+// or array type t, implements json.Marshaler or encoding.TextMarshaler.
+// time.Time is left to the time handling so that its tag options are
+// honoured.
+func encodesItself(t reflect.Type) bool {
+	for t.Kind() == reflect.Ptr || t.Kind() == reflect.Slice || t.Kind() == reflect.Array {
+		t = t.Elem()
 	}
-	return false
+
+	if t == reflect.TypeOf(time.Time{}) {
+		return false
+	}
+
+	pt := reflect.PtrTo(t)
+	return pt.Implements(jsonMarshalerType) || pt.Implements(textMarshalerType)
+}
+
+// marshalAttribute delegates encoding of an attribute to its type's
+// MarshalJSON or MarshalText method by way of encoding/json.
+func marshalAttribute(fieldValue reflect.Value) (json.RawMessage, error) {
+	if fieldValue.Kind() == reflect.Slice && fieldValue.IsNil() {
+		return json.RawMessage("[]"), nil
+	}
+
+	// Marshal through a pointer so methods with pointer receivers are found
+	v := reflect.New(fieldValue.Type())
+	v.Elem().Set(fieldValue)
+
+	data, err := json.Marshal(v.Interface())
+	if err != nil {
+		return nil, err
+	}
+	return json.RawMessage(data), nil
 }
 
 func visitModelNodeAttribute(args []string, node *Node, fieldValue reflect.Value) error {
-	var omitEmpty, iso8601, rfc3339 bool
+	// Write-only attributes, such as passwords, are decoded but never encoded
+	if hasTagOption(args, annotationWriteOnly) {
+		return nil
+	}
+
+	if err := checkTimeLayout(args); err != nil {
+		return err
+	}
+
+	var omitEmpty bool
 
 	if len(args) > 2 {
 		for _, arg := range args[2:] {
-			switch arg {
-			case annotationOmitEmpty:
+			if arg == annotationOmitEmpty {
 				omitEmpty = true
-			case annotationISO8601:
-				iso8601 = true
-			case annotationRFC3339:
-				rfc3339 = true
 			}
 		}
 	}
@@ -286,20 +333,38 @@ func visitModelNodeAttribute(args []string, node *Node, fieldValue reflect.Value
 		}
 	}
 
-	if fieldValue.Type() == reflect.TypeOf(time.Time{}) {
+	// Handle Optional[T]
+	if isOptional(fieldValue.Type()) {
+		o := optionalOf(fieldValue)
+		if !o.IsSpecified() {
+			return nil
+		}
+		if o.IsNull() {
+			node.Attributes[args[1]] = json.RawMessage("null")
+			return nil
+		}
+		fieldValue = o.reflectValue()
+	}
+
+	if encodesItself(fieldValue.Type()) {
+		// See if we need to omit this field
+		if omitEmpty && reflect.DeepEqual(fieldValue.Interface(), reflect.Zero(fieldValue.Type()).Interface()) {
+			return nil
+		}
+
+		raw, err := marshalAttribute(fieldValue)
+		if err != nil {
+			return fmt.Errorf("failed to marshal attribute %q: %w", args[1], err)
+		}
+		node.Attributes[args[1]] = raw
+	} else if fieldValue.Type() == reflect.TypeOf(time.Time{}) {
 		t := fieldValue.Interface().(time.Time)
 
 		if t.IsZero() {
 			return nil
 		}
 
-		if iso8601 {
-			node.Attributes[args[1]] = t.UTC().Format(iso8601TimeFormat)
-		} else if rfc3339 {
-			node.Attributes[args[1]] = t.UTC().Format(time.RFC3339)
-		} else {
-			node.Attributes[args[1]] = t.Unix()
-		}
+		node.Attributes[args[1]] = parseTimeFormat(args).format(t)
 	} else if fieldValue.Type() == reflect.TypeOf(new(time.Time)) {
 		// A time pointer may be nil
 		if fieldValue.IsNil() {
@@ -315,13 +380,7 @@ func visitModelNodeAttribute(args []string, node *Node, fieldValue reflect.Value
 				return nil
 			}
 
-			if iso8601 {
-				node.Attributes[args[1]] = tm.UTC().Format(iso8601TimeFormat)
-			} else if rfc3339 {
-				node.Attributes[args[1]] = tm.UTC().Format(time.RFC3339)
-			} else {
-				node.Attributes[args[1]] = tm.Unix()
-			}
+			node.Attributes[args[1]] = parseTimeFormat(args).format(*tm)
 		}
 	} else {
 		// Dealing with a fieldValue that is not a time
@@ -353,7 +412,7 @@ func visitModelNodeAttribute(args []string, node *Node, fieldValue reflect.Value
 			// nested structs, which should fall through to "primitive" handling below
 			if hasJSONAPIAnnotations(t) {
 				// Nested slice of object attributes
-				manyNested, err := visitModelNodeRelationships(fieldValue, nil, false)
+				manyNested, err := newEncoder(false, nil).visitModelNodeRelationships(fieldValue)
 				if err != nil {
 					return fmt.Errorf("failed to marshal slice of nested attribute %q: %w", args[1], err)
 				}
@@ -376,7 +435,7 @@ func visitModelNodeAttribute(args []string, node *Node, fieldValue reflect.Value
 			// nested structs, which should fall through to "primitive" handling below
 			if hasJSONAPIAnnotations(t) {
 				// Nested object attribute
-				nested, err := visitModelNode(fieldValue.Interface(), nil, false)
+				nested, err := newEncoder(false, nil).visitModelNode(fieldValue.Interface())
 				if err != nil {
 					return fmt.Errorf("failed to marshal nested attribute %q: %w", args[1], err)
 				}
@@ -385,6 +444,28 @@ func visitModelNodeAttribute(args []string, node *Node, fieldValue reflect.Value
 			}
 		}
 
+		// Maps, and slices of anything but primitives, are visited member by
+		// member so that their values are encoded like attributes of the same
+		// type
+		isMap := fieldValue.Kind() == reflect.Map && !fieldValue.IsNil()
+		isSlice := fieldValue.Kind() == reflect.Slice && !fieldValue.IsNil() && fieldValue.Type().Elem().Kind() != reflect.Uint8
+		isArray := fieldValue.Kind() == reflect.Array
+		if isMap || (isSlice || isArray) &&
+			(!isPrimitiveType(fieldValue.Type().Elem()) || isQuotedNumber(args, fieldValue.Type().Elem())) {
+			value, err := visitCollectionAttribute(args, fieldValue)
+			if err != nil {
+				return fmt.Errorf("failed to marshal attribute %q: %w", args[1], err)
+			}
+			node.Attributes[args[1]] = value
+			return nil
+		}
+
+		// Numbers are encoded as strings with the "string" option
+		if isQuotedNumber(args, fieldValue.Type()) {
+			node.Attributes[args[1]] = quoteNumber(fieldValue)
+			return nil
+		}
+
 		// Primitive attribute
 		strAttr, ok := fieldValue.Interface().(string)
 		if ok {
@@ -406,13 +487,112 @@ func visitModelNodeAttribute(args []string, node *Node, fieldValue reflect.Value
 	return nil
 }
 
-func visitModelNodeRelation(model any, annotation string, args []string, node *Node, fieldValue reflect.Value, included *map[string]*Node, sideload bool) error {
+// quoteNumber formats the number held by fieldValue, or the number it points
+// to, as a string. A nil pointer is encoded as null.
+func quoteNumber(fieldValue reflect.Value) interface{} {
+	if fieldValue.Kind() == reflect.Ptr {
+		if fieldValue.IsNil() {
+			return nil
+		}
+		fieldValue = fieldValue.Elem()
+	}
+
+	switch fieldValue.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(fieldValue.Int(), 10)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return strconv.FormatUint(fieldValue.Uint(), 10)
+	default:
+		return strconv.FormatFloat(fieldValue.Float(), 'g', -1, fieldValue.Type().Bits())
+	}
+}
+
+// isPrimitiveType reports whether values of type t, or of the type t points
+// to, are encoded the same way by encoding/json as by visitModelNodeAttribute.
+func isPrimitiveType(t reflect.Type) bool {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	switch t.Kind() {
+	case reflect.Bool, reflect.String, reflect.Interface,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
+
+// visitCollectionAttribute encodes each member of a map, or each element of
+// a slice or array, as an attribute described by args.
+func visitCollectionAttribute(args []string, fieldValue reflect.Value) (interface{}, error) {
+	if fieldValue.Kind() == reflect.Slice || fieldValue.Kind() == reflect.Array {
+		elems := make([]interface{}, fieldValue.Len())
+		for i := range elems {
+			elem, err := visitElementAttribute(args, fieldValue.Index(i))
+			if err != nil {
+				return nil, fmt.Errorf("element %d: %w", i, err)
+			}
+			elems[i] = elem
+		}
+		return elems, nil
+	}
+
+	members := make(map[string]interface{}, fieldValue.Len())
+	iter := fieldValue.MapRange()
+	for iter.Next() {
+		name, err := mapKeyName(iter.Key())
+		if err != nil {
+			return nil, err
+		}
+
+		member, err := visitElementAttribute(args, iter.Value())
+		if err != nil {
+			return nil, fmt.Errorf("member %q: %w", name, err)
+		}
+		members[name] = member
+	}
+	return members, nil
+}
+
+// visitElementAttribute encodes a single map member or slice element with
+// the attribute options in args. Elements are never omitted, so omitempty is
+// dropped and a value that would have been omitted is encoded as null.
+func visitElementAttribute(args []string, fieldValue reflect.Value) (interface{}, error) {
+	elemArgs := []string{args[0], args[1]}
+	for _, arg := range args[2:] {
+		if arg != annotationOmitEmpty {
+			elemArgs = append(elemArgs, arg)
+		}
+	}
+
+	node := new(Node)
+	if err := visitModelNodeAttribute(elemArgs, node, fieldValue); err != nil {
+		return nil, err
+	}
+	return node.Attributes[args[1]], nil
+}
+
+// mapKeyName converts a map key into the name of a JSON object member. As
+// with encoding/json, string and integer keys are supported.
+func mapKeyName(key reflect.Value) (string, error) {
+	switch key.Kind() {
+	case reflect.String:
+		return key.String(), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(key.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return strconv.FormatUint(key.Uint(), 10), nil
+	}
+	return "", fmt.Errorf("unsupported map key type %s", key.Type())
+}
+
+func (e *encoder) visitModelNodeRelation(model any, annotation string, args []string, node *Node, fieldValue reflect.Value) error {
 	var omitEmpty bool
 
 	//add support for 'omitempty' struct tag for marshaling as absent
-	if len(args) > 2 {
-		omitEmpty = args[2] == annotationOmitEmpty
-	}
+	omitEmpty = hasTagOption(args, annotationOmitEmpty)
 
 	if node.Relationships == nil {
 		node.Relationships = make(map[string]interface{})
@@ -420,20 +600,25 @@ func visitModelNodeRelation(model any, annotation string, args []string, node *N
 
 	// Handle NullableRelationship[T]
 	if strings.HasPrefix(fieldValue.Type().Name(), "NullableRelationship[") {
+		// handle unspecified
+		if fieldValue.Len() == 0 {
+			return nil
+		}
 
 		if fieldValue.MapIndex(reflect.ValueOf(false)).IsValid() {
-			innerTypeIsSlice := fieldValue.MapIndex(reflect.ValueOf(false)).Type().Kind() == reflect.Slice
-			// handle explicit null
-			if innerTypeIsSlice {
-				node.Relationships[args[1]] = json.RawMessage("[]")
+			// handle explicit null, which for a to-many relationship is
+			// written as empty since its linkage cannot be null
+			if fieldValue.Type().Elem().Kind() == reflect.Slice {
+				node.Relationships[args[1]] = &RelationshipManyNode{Data: []*Node{}}
 			} else {
-				node.Relationships[args[1]] = json.RawMessage("{\"data\":null}")
+				node.Relationships[args[1]] = &RelationshipOneNode{}
 			}
 			return nil
-		} else if fieldValue.MapIndex(reflect.ValueOf(true)).IsValid() {
-			// handle value
-			fieldValue = fieldValue.MapIndex(reflect.ValueOf(true))
 		}
+
+		// handle value, which is written even when empty
+		fieldValue = fieldValue.MapIndex(reflect.ValueOf(true))
+		omitEmpty = false
 	}
 
 	isSlice := fieldValue.Type().Kind() == reflect.Slice
@@ -444,10 +629,6 @@ func visitModelNodeRelation(model any, annotation string, args []string, node *N
 		return nil
 	}
 
-	if fieldValue.Kind() == reflect.Map && fieldValue.IsNil() {
-		node.Relationships[args[1]] = &RelationshipOneNode{}
-		return nil
-	}
 	if omitEmpty &&
 		(isSlice && fieldValue.Len() < 1 ||
 			(!isSlice && fieldValue.IsNil())) {
@@ -523,21 +704,16 @@ func visitModelNodeRelation(model any, annotation string, args []string, node *N
 
 	if isSlice {
 		// to-many relationship
-		relationship, err := visitModelNodeRelationships(
-			fieldValue,
-			included,
-			sideload,
-		)
+		relationship, err := e.visitModelNodeRelationships(fieldValue)
 		if err != nil {
 			return err
 		}
 		relationship.Links = relLinks
 		relationship.Meta = relMeta
 
-		if sideload {
+		if e.sideload {
 			shallowNodes := []*Node{}
 			for _, n := range relationship.Data {
-				appendIncluded(included, n)
 				shallowNodes = append(shallowNodes, toShallowNode(n))
 			}
 
@@ -558,18 +734,12 @@ func visitModelNodeRelation(model any, annotation string, args []string, node *N
 			return nil
 		}
 
-		relationship, err := visitModelNode(
-			fieldValue.Interface(),
-			included,
-			sideload,
-		)
-
+		relationship, err := e.visitRelatedNode(fieldValue.Interface())
 		if err != nil {
 			return err
 		}
 
-		if sideload {
-			appendIncluded(included, relationship)
+		if e.sideload {
 			node.Relationships[args[1]] = &RelationshipOneNode{
 				Data:  toShallowNode(relationship),
 				Links: relLinks,
@@ -586,8 +756,7 @@ func visitModelNodeRelation(model any, annotation string, args []string, node *N
 	return nil
 }
 
-func visitModelNode(model interface{}, included *map[string]*Node,
-	sideload bool) (*Node, error) {
+func (e *encoder) visitModelNode(model interface{}) (*Node, error) {
 	node := new(Node)
 
 	var er error
@@ -606,15 +775,25 @@ func visitModelNode(model interface{}, included *map[string]*Node,
 		modelType = value.Type()
 	}
 
-	for i := 0; i < modelValue.NumField(); i++ {
-		fieldValue := modelValue.Field(i)
-		structField := modelValue.Type().Field(i)
-		tag := structField.Tag.Get(annotationJSONAPI)
-		if tag == "" {
+	if linkage := resourceIdentifier(model); linkage != nil {
+		key := resourceKey(linkage)
+		e.visiting[key] = true
+		defer func() {
+			delete(e.visiting, key)
+			e.visited[key] = modelAddress(model)
+		}()
+	} else if address := modelAddress(model); address != 0 {
+		e.visitingAddresses[address] = true
+		defer delete(e.visitingAddresses, address)
+	}
+
+	for _, structField := range modelFields(modelType) {
+		// Fields promoted from a nil embedded struct pointer are left out
+		fieldValue, ok := fieldByIndex(modelValue, structField.Index, false)
+		if !ok {
 			continue
 		}
-
-		fieldType := modelType.Field(i)
+		tag := structField.Tag.Get(annotationJSONAPI)
 
 		args := strings.Split(tag, annotationSeparator)
 
@@ -632,47 +811,7 @@ func visitModelNode(model interface{}, included *map[string]*Node,
 		}
 
 		if annotation == annotationPrimary {
-			v := fieldValue
-
-			// Deal with PTRS
-			var kind reflect.Kind
-			if fieldValue.Kind() == reflect.Ptr {
-				kind = fieldType.Type.Elem().Kind()
-				v = reflect.Indirect(fieldValue)
-			} else {
-				kind = fieldType.Type.Kind()
-			}
-
-			// Handle allowed types
-			switch kind {
-			case reflect.String:
-				node.ID = v.Interface().(string)
-			case reflect.Int:
-				node.ID = strconv.FormatInt(int64(v.Interface().(int)), 10)
-			case reflect.Int8:
-				node.ID = strconv.FormatInt(int64(v.Interface().(int8)), 10)
-			case reflect.Int16:
-				node.ID = strconv.FormatInt(int64(v.Interface().(int16)), 10)
-			case reflect.Int32:
-				node.ID = strconv.FormatInt(int64(v.Interface().(int32)), 10)
-			case reflect.Int64:
-				node.ID = strconv.FormatInt(v.Interface().(int64), 10)
-			case reflect.Uint:
-				node.ID = strconv.FormatUint(uint64(v.Interface().(uint)), 10)
-			case reflect.Uint8:
-				node.ID = strconv.FormatUint(uint64(v.Interface().(uint8)), 10)
-			case reflect.Uint16:
-				node.ID = strconv.FormatUint(uint64(v.Interface().(uint16)), 10)
-			case reflect.Uint32:
-				node.ID = strconv.FormatUint(uint64(v.Interface().(uint32)), 10)
-			case reflect.Uint64:
-				node.ID = strconv.FormatUint(v.Interface().(uint64), 10)
-			default:
-				// We had a JSON float (numeric), but our field was not one of the
-				// allowed numeric types
-				er = ErrBadJSONAPIID
-			}
-
+			node.ID, er = primaryID(fieldValue)
 			if er != nil {
 				break
 			}
@@ -689,7 +828,7 @@ func visitModelNode(model interface{}, included *map[string]*Node,
 				break
 			}
 		} else if annotation == annotationRelation || annotation == annotationPolyRelation {
-			er = visitModelNodeRelation(model, annotation, args, node, fieldValue, included, sideload)
+			er = e.visitModelNodeRelation(model, annotation, args, node, fieldValue)
 			if er != nil {
 				break
 			}
@@ -698,6 +837,9 @@ func visitModelNode(model interface{}, included *map[string]*Node,
 			// The Linkable interface methods are used for marshaling data in a response.
 		} else if annotation == annotationMeta {
 
+		} else if annotation == annotationRelLinks || annotation == annotationRelMeta {
+			// Relationship links and meta fields are likewise only for unmarshaling;
+			// RelationshipLinkable and RelationshipMetable are used when marshaling.
 		} else {
 			er = ErrBadJSONAPIStructTag
 			break
@@ -745,19 +887,18 @@ func toShallowNode(node *Node) *Node {
 	return ret
 }
 
-func visitModelNodeRelationships(models reflect.Value, included *map[string]*Node,
-	sideload bool) (*RelationshipManyNode, error) {
+func (e *encoder) visitModelNodeRelationships(models reflect.Value) (*RelationshipManyNode, error) {
 	nodes := []*Node{}
 
 	for i := 0; i < models.Len(); i++ {
 		model := models.Index(i)
-		if !model.IsValid() || (model.Kind() == reflect.Pointer && model.IsNil()) {
+		if !model.IsValid() || ((model.Kind() == reflect.Pointer || model.Kind() == reflect.Interface) && model.IsNil()) {
 			return nil, ErrUnexpectedNil
 		}
 
 		n := model.Interface()
 
-		node, err := visitModelNode(n, included, sideload)
+		node, err := e.visitRelatedNode(n)
 		if err != nil {
 			return nil, err
 		}
@@ -768,6 +909,169 @@ func visitModelNodeRelationships(models reflect.Value, included *map[string]*Nod
 	return &RelationshipManyNode{Data: nodes}, nil
 }
 
+// encoder holds the state shared by every node encoded into a single
+// response document.
+type encoder struct {
+	// sideload is set when related resources are encoded into "included",
+	// which maps their "type,id" keys to their nodes, rather than embedded
+	// in the relationships of the resources referring to them.
+	sideload bool
+	included map[string]*Node
+
+	// visiting holds the keys of the resources whose nodes are being built,
+	// so that relationships leading back to one of them are encoded as
+	// resource linkage only.
+	visiting map[string]bool
+	// visitingAddresses holds the addresses of the models without an ID
+	// whose nodes are being built, as those can only be told apart by their
+	// address.
+	visitingAddresses map[uintptr]bool
+	// visited maps the keys of the resources whose nodes have been built to
+	// the address of the model each was built from, so that a model reached
+	// again is not sideloaded a second time.
+	visited map[string]uintptr
+	// depth counts the relationships between the primary data and the
+	// resource being encoded.
+	depth int
+
+	opts marshalOptions
+}
+
+func newEncoder(sideload bool, opts []MarshalOption) *encoder {
+	e := &encoder{
+		sideload:          sideload,
+		included:          map[string]*Node{},
+		visiting:          map[string]bool{},
+		visitingAddresses: map[uintptr]bool{},
+		visited:           map[string]uintptr{},
+	}
+	for _, opt := range opts {
+		opt(&e.opts)
+	}
+	return e
+}
+
+// visitRelatedNode builds the node of a model related to the resource being
+// encoded, and sideloads it when encoding into "included". A model that is
+// being encoded further up a cycle of relationships, or that lies beyond
+// MaxRelationshipDepth, is encoded as resource linkage only, as is a model
+// that has already been sideloaded. Embedded models are encoded in full
+// wherever they are referred to. A model without an ID that is reached again
+// through a cycle has no linkage to be encoded as, and is left with its type
+// alone.
+func (e *encoder) visitRelatedNode(model interface{}) (*Node, error) {
+	address := modelAddress(model)
+	if linkage := resourceIdentifier(model); linkage != nil {
+		key := resourceKey(linkage)
+		if e.visiting[key] || !e.relationDepthLeft() {
+			return linkage, nil
+		}
+		if e.sideload && address != 0 && e.visited[key] == address {
+			return linkage, nil
+		}
+	} else if address != 0 && e.visitingAddresses[address] {
+		return &Node{Type: resourceType(model)}, nil
+	}
+
+	e.depth++
+	node, err := e.visitModelNode(model)
+	e.depth--
+	if err != nil {
+		return nil, err
+	}
+
+	if e.sideload {
+		appendIncluded(&e.included, node)
+	}
+	return node, nil
+}
+
+// relationDepthLeft reports whether the relationships of the resource being
+// encoded may still be encoded in full under MaxRelationshipDepth.
+func (e *encoder) relationDepthLeft() bool {
+	return !e.opts.limitRelationshipDepth || e.depth < e.opts.maxRelationshipDepth
+}
+
+// resourceIdentifier returns a node holding only the type and ID of model,
+// read from its `primary` field, or nil if model has no ID to identify it by.
+func resourceIdentifier(model interface{}) *Node {
+	value := reflect.Indirect(reflect.ValueOf(model))
+	if value.Kind() != reflect.Struct {
+		return nil
+	}
+
+	for _, field := range modelFields(value.Type()) {
+		args := strings.Split(field.Tag.Get(annotationJSONAPI), annotationSeparator)
+		if len(args) < 2 || args[0] != annotationPrimary {
+			continue
+		}
+
+		fieldValue, ok := fieldByIndex(value, field.Index, false)
+		if !ok {
+			return nil
+		}
+		id, err := primaryID(fieldValue)
+		if err != nil || id == "" {
+			return nil
+		}
+		return &Node{Type: args[1], ID: id}
+	}
+	return nil
+}
+
+// resourceType returns the type of model, read from the tag of its
+// `primary` field.
+func resourceType(model interface{}) string {
+	value := reflect.Indirect(reflect.ValueOf(model))
+	if value.Kind() != reflect.Struct {
+		return ""
+	}
+
+	for _, field := range modelFields(value.Type()) {
+		args := strings.Split(field.Tag.Get(annotationJSONAPI), annotationSeparator)
+		if len(args) >= 2 && args[0] == annotationPrimary {
+			return args[1]
+		}
+	}
+	return ""
+}
+
+// modelAddress returns the address model points to, or 0 if model is not a
+// pointer.
+func modelAddress(model interface{}) uintptr {
+	if value := reflect.ValueOf(model); value.Kind() == reflect.Ptr {
+		return value.Pointer()
+	}
+	return 0
+}
+
+// primaryID formats the value of a `primary` field as a resource ID.
+func primaryID(fieldValue reflect.Value) (string, error) {
+	v := fieldValue
+
+	// Deal with PTRS
+	if fieldValue.Kind() == reflect.Ptr {
+		if fieldValue.IsNil() {
+			return "", nil
+		}
+		v = fieldValue.Elem()
+	}
+
+	// Handle allowed types
+	switch v.Kind() {
+	case reflect.String:
+		return v.String(), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(v.Uint(), 10), nil
+	default:
+		// We had a JSON float (numeric), but our field was not one of the
+		// allowed numeric types
+		return "", ErrBadJSONAPIID
+	}
+}
+
 func appendIncluded(m *map[string]*Node, nodes ...*Node) {
 	included := *m
 
@@ -267,6 +267,189 @@ func TestMarshal_attrStringSlice(t *testing.T) {
 	}
 }
 
+func TestMarshal_attrMarshalers(t *testing.T) {
+	discount := Money{105, "EUR"}
+	invoice := &Invoice{
+		ID:       "1",
+		Total:    Money{1250, "EUR"},
+		Discount: &discount,
+		Lines:    []Money{{1000, "EUR"}, {250, "EUR"}},
+		Origin:   Point{1, 2},
+		Route:    []*Point{{3, 4}, {5, 6}},
+	}
+	invoice.Tip.Set(Money{99, "EUR"})
+	invoice.Dest.SetNull()
+
+	out := bytes.NewBuffer(nil)
+	if err := MarshalPayload(out, invoice); err != nil {
+		t.Fatal(err)
+	}
+
+	var jsonData map[string]interface{}
+	if err := json.Unmarshal(out.Bytes(), &jsonData); err != nil {
+		t.Fatal(err)
+	}
+	attributes := jsonData["data"].(map[string]interface{})["attributes"].(map[string]interface{})
+
+	expected := map[string]interface{}{
+		"total":    "12.50 EUR",
+		"discount": "1.05 EUR",
+		"lines":    []interface{}{"10.00 EUR", "2.50 EUR"},
+		"tip":      "0.99 EUR",
+		"origin":   []interface{}{1.0, 2.0},
+		"route":    []interface{}{[]interface{}{3.0, 4.0}, []interface{}{5.0, 6.0}},
+		"dest":     nil,
+	}
+	if !reflect.DeepEqual(expected, attributes) {
+		t.Fatalf("Was expecting attributes %v, got %v", expected, attributes)
+	}
+
+	// And back again
+	decoded := new(Invoice)
+	if err := UnmarshalPayload(bytes.NewReader(out.Bytes()), decoded); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(invoice, decoded) {
+		t.Fatalf("Was expecting %+v to round trip, got %+v", invoice, decoded)
+	}
+}
+
+func TestMarshal_attrMaps(t *testing.T) {
+	profile := &Profile{
+		ID:        "1",
+		Settings:  Settings{"theme": "dark", "size": 12.0},
+		Limits:    map[string]int{"daily": 10},
+		Flags:     map[int]bool{7: true},
+		Addresses: map[string]Address{"home": {Street: "1 Main St", City: "Springfield"}},
+		Contacts:  map[string]*Address{"work": {Street: "2 Side St", City: "Shelbyville"}},
+		Weights:   map[string]map[string]float64{"a": {"x": 0.5}},
+		History:   []map[string]string{{"event": "created"}},
+	}
+
+	out := bytes.NewBuffer(nil)
+	if err := MarshalPayload(out, profile); err != nil {
+		t.Fatal(err)
+	}
+
+	var jsonData map[string]interface{}
+	if err := json.Unmarshal(out.Bytes(), &jsonData); err != nil {
+		t.Fatal(err)
+	}
+	attributes := jsonData["data"].(map[string]interface{})["attributes"].(map[string]interface{})
+
+	expected := map[string]interface{}{
+		"settings":  map[string]interface{}{"theme": "dark", "size": 12.0},
+		"limits":    map[string]interface{}{"daily": 10.0},
+		"flags":     map[string]interface{}{"7": true},
+		"addresses": map[string]interface{}{"home": map[string]interface{}{"street": "1 Main St", "city": "Springfield"}},
+		"contacts":  map[string]interface{}{"work": map[string]interface{}{"street": "2 Side St", "city": "Shelbyville"}},
+		"weights":   map[string]interface{}{"a": map[string]interface{}{"x": 0.5}},
+		"history":   []interface{}{map[string]interface{}{"event": "created"}},
+	}
+	if !reflect.DeepEqual(expected, attributes) {
+		t.Fatalf("Was expecting attributes %v, got %v", expected, attributes)
+	}
+
+	// And back again
+	decoded := new(Profile)
+	if err := UnmarshalPayload(bytes.NewReader(out.Bytes()), decoded); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(profile, decoded) {
+		t.Fatalf("Was expecting %+v to round trip, got %+v", profile, decoded)
+	}
+}
+
+func TestMarshal_attrSlices(t *testing.T) {
+	a := "a"
+	stamp := time.Unix(1471422432, 0)
+	m := &Measurements{
+		ID:     "1",
+		Counts: []int{1, 2, 3},
+		Small:  []int8{-1, 127},
+		Ratios: []float64{0.25, 1.5},
+		Flags:  []bool{true, false},
+		Labels: []*string{&a, nil},
+		Taken:  []time.Time{time.Date(2016, 8, 17, 8, 27, 12, 0, time.UTC)},
+		Stamps: []*time.Time{&stamp},
+		Grid:   [][]string{{"a", "b"}, {"c"}},
+		Pair:   [2]int{4, 5},
+		Blob:   []byte("hello"),
+	}
+
+	out := bytes.NewBuffer(nil)
+	if err := MarshalPayload(out, m); err != nil {
+		t.Fatal(err)
+	}
+
+	var jsonData map[string]interface{}
+	if err := json.Unmarshal(out.Bytes(), &jsonData); err != nil {
+		t.Fatal(err)
+	}
+	attributes := jsonData["data"].(map[string]interface{})["attributes"].(map[string]interface{})
+
+	if e, a := []interface{}{"2016-08-17T08:27:12Z"}, attributes["taken"]; !reflect.DeepEqual(e, a) {
+		t.Fatalf("Was expecting taken to be %v, got %v", e, a)
+	}
+	if e, a := []interface{}{1471422432.0}, attributes["stamps"]; !reflect.DeepEqual(e, a) {
+		t.Fatalf("Was expecting stamps to be %v, got %v", e, a)
+	}
+
+	// And back again
+	decoded := new(Measurements)
+	if err := UnmarshalPayload(bytes.NewReader(out.Bytes()), decoded); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(m, decoded) {
+		t.Fatalf("Was expecting %+v to round trip, got %+v", m, decoded)
+	}
+}
+
+func TestMarshal_attrQuotedNumbers(t *testing.T) {
+	quotedPtr := uint64(18446744073709551615)
+	in := &BigNumbers{
+		ID:          1,
+		Int64:       9007199254740993,
+		Quoted:      9007199254740993,
+		QuotedPtr:   &quotedPtr,
+		QuotedSlice: []int64{1, -2},
+	}
+
+	out := bytes.NewBuffer(nil)
+	if err := MarshalPayload(out, in); err != nil {
+		t.Fatal(err)
+	}
+
+	var jsonData struct {
+		Data struct {
+			Attributes map[string]json.RawMessage `json:"attributes"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(out.Bytes(), &jsonData); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, expected := range map[string]string{
+		"int64":        `9007199254740993`,
+		"quoted":       `"9007199254740993"`,
+		"quoted-ptr":   `"18446744073709551615"`,
+		"quoted-slice": `["1","-2"]`,
+	} {
+		if actual := string(jsonData.Data.Attributes[name]); actual != expected {
+			t.Errorf("Was expecting %s to be %s, got %s", name, expected, actual)
+		}
+	}
+
+	// And back again
+	decoded := new(BigNumbers)
+	if err := UnmarshalPayload(bytes.NewReader(out.Bytes()), decoded); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(in, decoded) {
+		t.Fatalf("Was expecting %+v to round trip, got %+v", in, decoded)
+	}
+}
+
 func TestWithoutOmitsEmptyAnnotationOnRelation(t *testing.T) {
 	blog := &Blog{}
 
@@ -952,6 +1135,77 @@ func TestMarshal_Times(t *testing.T) {
 	}
 }
 
+func TestMarshal_TimeFormats(t *testing.T) {
+	aTime := time.Date(2016, 8, 17, 8, 27, 12, 23849000, time.UTC)
+	offset := time.FixedZone("", -5*60*60)
+	nano := time.Date(2016, 8, 17, 8, 27, 12, 0, time.UTC)
+
+	input := &TimeFormatsModel{
+		ID:          5,
+		UnixMilli:   aTime,
+		UnixNano:    &nano,
+		RFC3339Nano: aTime.In(offset),
+		Date:        time.Date(2016, 8, 17, 0, 0, 0, 0, time.UTC),
+		Layout:      time.Date(2016, 8, 17, 8, 27, 0, 0, offset),
+		KeepOffset:  time.Date(2016, 8, 17, 3, 27, 12, 0, offset),
+		Nullable:    NewNullableAttrWithValue(time.Date(2016, 8, 18, 0, 0, 0, 0, time.UTC)),
+		Slice:       []time.Time{aTime},
+	}
+
+	out := bytes.NewBuffer(nil)
+	if err := MarshalPayload(out, input); err != nil {
+		t.Fatal(err)
+	}
+
+	var jsonData map[string]interface{}
+	if err := json.Unmarshal(out.Bytes(), &jsonData); err != nil {
+		t.Fatal(err)
+	}
+	attributes := jsonData["data"].(map[string]interface{})["attributes"].(map[string]interface{})
+
+	expected := map[string]interface{}{
+		"unixms":      float64(aTime.UnixMilli()),
+		"unixnano":    float64(nano.UnixNano()),
+		"rfc3339nano": "2016-08-17T08:27:12.023849Z",
+		"date":        "2016-08-17",
+		"layout":      "17 Aug 16 13:27 +0000",
+		"keepoffset":  "2016-08-17T03:27:12-05:00",
+		"nullable":    "2016-08-18",
+		"slice":       []interface{}{float64(aTime.UnixMilli())},
+	}
+	if !reflect.DeepEqual(expected, attributes) {
+		t.Fatalf("Was expecting attributes %v, got %v", expected, attributes)
+	}
+
+	decoded := new(TimeFormatsModel)
+	if err := UnmarshalPayload(bytes.NewReader(out.Bytes()), decoded); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		desc      string
+		got, want time.Time
+	}{
+		{"unixms", decoded.UnixMilli, aTime.Truncate(time.Millisecond)},
+		{"unixnano", *decoded.UnixNano, nano},
+		{"rfc3339nano", decoded.RFC3339Nano, aTime},
+		{"date", decoded.Date, input.Date},
+		{"layout", decoded.Layout, input.Layout},
+		{"keepoffset", decoded.KeepOffset, input.KeepOffset},
+		{"slice", decoded.Slice[0], aTime.Truncate(time.Millisecond)},
+	} {
+		if !tc.got.Equal(tc.want) {
+			t.Errorf("%s: got %v, want %v", tc.desc, tc.got, tc.want)
+		}
+	}
+	if _, offset := decoded.KeepOffset.Zone(); offset != -5*60*60 {
+		t.Errorf("keepoffset: was expecting the offset to be preserved, got %v", decoded.KeepOffset)
+	}
+	if got, err := decoded.Nullable.Get(); err != nil || !got.Equal(time.Date(2016, 8, 18, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("nullable: got %v (%v)", got, err)
+	}
+}
+
 func TestNullableRelationship(t *testing.T) {
 	comment := &Comment{
 		ID:   5,
@@ -1206,6 +1460,126 @@ func TestNullableAttr_Bool(t *testing.T) {
 	}
 }
 
+func TestNullableRelationship_toManyAndPoly(t *testing.T) {
+	for _, tc := range []struct {
+		desc     string
+		input    *WithNullableRelationships
+		expected string
+	}{
+		{
+			desc:     "unspecified",
+			input:    &WithNullableRelationships{ID: 1},
+			expected: `{}`,
+		},
+		{
+			desc: "null",
+			input: &WithNullableRelationships{
+				ID:       1,
+				Comments: NewNullNullableRelationship[[]*Comment](),
+				Hero:     NewNullNullableRelationship[*OneOfMedia](),
+			},
+			expected: `{"comments":{"data":[]},"hero":{"data":null}}`,
+		},
+		{
+			desc: "empty",
+			input: &WithNullableRelationships{
+				ID:       1,
+				Comments: NewNullableRelationshipWithValue([]*Comment{}),
+				Media:    NewNullableRelationshipWithValue([]*OneOfMedia{}),
+			},
+			expected: `{"comments":{"data":[]},"media":{"data":[]}}`,
+		},
+		{
+			desc: "values",
+			input: &WithNullableRelationships{
+				ID:       1,
+				Comments: NewNullableRelationshipWithValue([]*Comment{{ID: 2}}),
+				Hero:     NewNullableRelationshipWithValue(&OneOfMedia{Image: &Image{ID: "i1"}}),
+				Media:    NewNullableRelationshipWithValue([]*OneOfMedia{{Video: &Video{ID: "v1"}}}),
+			},
+			expected: `{"comments":{"data":[{"type":"comments","id":"2"}]},"hero":{"data":{"type":"images","id":"i1"}},"media":{"data":[{"type":"videos","id":"v1"}]}}`,
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			out := bytes.NewBuffer(nil)
+			if err := MarshalPayloadWithoutIncluded(out, tc.input); err != nil {
+				t.Fatal(err)
+			}
+
+			var payload struct {
+				Data struct {
+					Relationships json.RawMessage `json:"relationships"`
+				} `json:"data"`
+			}
+			if err := json.Unmarshal(out.Bytes(), &payload); err != nil {
+				t.Fatal(err)
+			}
+
+			relationships := string(payload.Data.Relationships)
+			if relationships == "" {
+				relationships = `{}`
+			}
+			if relationships != tc.expected {
+				t.Fatalf("got %s, want %s", relationships, tc.expected)
+			}
+		})
+	}
+}
+
+func TestOptional(t *testing.T) {
+	for _, tc := range []struct {
+		desc     string
+		input    *WithOptionals
+		expected string
+	}{
+		{
+			desc:     "unspecified",
+			input:    &WithOptionals{ID: 1},
+			expected: `{"type":"with-optionals","id":"1"}`,
+		},
+		{
+			desc:     "null",
+			input:    &WithOptionals{ID: 1, Name: NewNullOptional[string]()},
+			expected: `{"type":"with-optionals","id":"1","attributes":{"name":null}}`,
+		},
+		{
+			desc: "values",
+			input: &WithOptionals{
+				ID:    1,
+				Count: NewOptional(0),
+				Born:  NewOptional(time.Date(2016, 8, 17, 8, 27, 12, 0, time.UTC)),
+			},
+			expected: `{"type":"with-optionals","id":"1","attributes":{"born":"2016-08-17T08:27:12Z","count":0}}`,
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			out := bytes.NewBuffer(nil)
+			if err := MarshalPayload(out, tc.input); err != nil {
+				t.Fatal(err)
+			}
+
+			var payload struct {
+				Data json.RawMessage `json:"data"`
+			}
+			if err := json.Unmarshal(out.Bytes(), &payload); err != nil {
+				t.Fatal(err)
+			}
+			if got := string(payload.Data); got != tc.expected {
+				t.Fatalf("got %s, want %s", got, tc.expected)
+			}
+
+			// Optionals round trip, and compare with ==
+			decoded := new(WithOptionals)
+			if err := UnmarshalPayload(bytes.NewReader(out.Bytes()), decoded); err != nil {
+				t.Fatal(err)
+			}
+			if decoded.Name != tc.input.Name || decoded.Count != tc.input.Count || !decoded.Born.value.Equal(tc.input.Born.value) {
+				t.Fatalf("got %+v, want %+v", decoded, tc.input)
+			}
+		})
+	}
+}
+
 func TestSupportsLinkable(t *testing.T) {
 	testModel := &Blog{
 		ID:        5,
@@ -1582,6 +1956,163 @@ func TestMarshal_InvalidIntefaceArgument(t *testing.T) {
 	}
 }
 
+// testFriends returns users referring to one another in a cycle:
+// alice -> bob -> alice, and bob -> carol -> bob.
+func testFriends() (alice, bob, carol *User) {
+	alice = &User{ID: 1, Name: "Alice"}
+	bob = &User{ID: 2, Name: "Bob"}
+	carol = &User{ID: 3, Name: "Carol"}
+
+	alice.BestFriend = bob
+	bob.BestFriend = alice
+	bob.Friends = []*User{alice, carol}
+	carol.BestFriend = bob
+	return alice, bob, carol
+}
+
+// includedIDs returns the sorted IDs of the resources in "included".
+func includedIDs(included []*Node) []string {
+	ids := make([]string, len(included))
+	for i, n := range included {
+		ids[i] = n.ID
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+func TestMarshalPayload_relationshipCycles(t *testing.T) {
+	alice, _, _ := testFriends()
+
+	payload, err := Marshal(alice)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	included := payload.(*OnePayload).Included
+	if ids := includedIDs(included); !reflect.DeepEqual(ids, []string{"2", "3"}) {
+		t.Fatalf("Was expecting bob and carol to be included once each, got %v", ids)
+	}
+
+	// The whole graph survives a round trip
+	out := bytes.NewBuffer(nil)
+	if err := MarshalPayload(out, alice); err != nil {
+		t.Fatal(err)
+	}
+	decoded := new(User)
+	if err := UnmarshalPayload(out, decoded); err != nil {
+		t.Fatal(err)
+	}
+	bob := decoded.BestFriend
+	if bob.Name != "Bob" || bob.BestFriend != decoded || bob.Friends[1].BestFriend != bob {
+		t.Fatalf("Was expecting the cycle to round trip, got %+v", bob)
+	}
+}
+
+func TestMarshalPayload_manyRelationshipCycles(t *testing.T) {
+	alice, bob, _ := testFriends()
+
+	payload, err := Marshal([]*User{alice, bob})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	many := payload.(*ManyPayload)
+	if len(many.Data) != 2 || many.Data[1].Attributes["name"] != "Bob" {
+		t.Fatalf("Was expecting bob to be encoded in full in data, got %+v", many.Data)
+	}
+	if ids := includedIDs(many.Included); !reflect.DeepEqual(ids, []string{"3"}) {
+		t.Fatalf("Was expecting only carol to be included, got %v", ids)
+	}
+}
+
+func TestMarshalPayload_relationshipCyclesWithoutIDs(t *testing.T) {
+	alice := &Person{Name: "Alice"}
+	bob := &Person{Name: "Bob", Partner: alice}
+	alice.Partner = bob
+
+	// Models without IDs are told apart by their addresses, so the cycle is
+	// cut instead of recursing forever
+	if err := MarshalPayload(bytes.NewBuffer(nil), alice); err != nil {
+		t.Fatal(err)
+	}
+
+	node, err := newEncoder(false, nil).visitModelNode(alice)
+	if err != nil {
+		t.Fatal(err)
+	}
+	partner := node.Relationships["partner"].(*RelationshipOneNode).Data
+	if partner.Attributes["name"] != "Bob" {
+		t.Fatalf("Was expecting bob to be embedded in full, got %+v", partner)
+	}
+	back := partner.Relationships["partner"].(*RelationshipOneNode).Data
+	if back.Type != "people" || back.ID != "" || back.Attributes != nil {
+		t.Fatalf("Was expecting alice to be encoded as a type alone, got %+v", back)
+	}
+}
+
+func TestMarshalOnePayloadEmbedded_sharedModel(t *testing.T) {
+	comment := &Comment{ID: 7, Body: "shared"}
+	post := &Post{ID: 1, Comments: []*Comment{comment}, LatestComment: comment}
+
+	out := bytes.NewBuffer(nil)
+	if err := MarshalOnePayloadEmbedded(out, post); err != nil {
+		t.Fatal(err)
+	}
+
+	var payload struct {
+		Data struct {
+			Relationships struct {
+				Comments struct {
+					Data []*Node `json:"data"`
+				} `json:"comments"`
+				LatestComment struct {
+					Data *Node `json:"data"`
+				} `json:"latest_comment"`
+			} `json:"relationships"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(out.Bytes(), &payload); err != nil {
+		t.Fatal(err)
+	}
+
+	relationships := payload.Data.Relationships
+	if len(relationships.Comments.Data) != 1 {
+		t.Fatalf("Was expecting 1 comment, got %d", len(relationships.Comments.Data))
+	}
+	// The comment is embedded in full both times it is referred to
+	for _, node := range []*Node{relationships.Comments.Data[0], relationships.LatestComment.Data} {
+		if node == nil || node.Attributes["body"] != "shared" {
+			t.Fatalf("Was expecting the comment's attributes to be embedded, got %+v", node)
+		}
+	}
+}
+
+func TestMarshalPayload_maxRelationshipDepth(t *testing.T) {
+	alice, _, _ := testFriends()
+
+	payload, err := Marshal(alice, MaxRelationshipDepth(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	included := payload.(*OnePayload).Included
+	if ids := includedIDs(included); !reflect.DeepEqual(ids, []string{"2"}) {
+		t.Fatalf("Was expecting only bob to be included, got %v", ids)
+	}
+	friends := included[0].Relationships["friends"].(*RelationshipManyNode)
+	if len(friends.Data) != 2 || friends.Data[1].ID != "3" || friends.Data[1].Attributes != nil {
+		t.Fatalf("Was expecting carol to be encoded as linkage, got %+v", friends.Data)
+	}
+
+	payload, err = Marshal(alice, MaxRelationshipDepth(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if included := payload.(*OnePayload).Included; len(included) != 0 {
+		t.Fatalf("Was expecting nothing to be included, got %v", includedIDs(included))
+	}
+}
+
 func testBlog() *Blog {
 	return &Blog{
 		ID:        5,
@@ -0,0 +1,103 @@
+package jsonapi
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+)
+
+var (
+	// ErrMissingMember is reported when a resource is created without the
+	// member of a field tagged `required`.
+	ErrMissingMember = errors.New("required member is missing")
+	// ErrReadOnlyMember is reported when a request document sends the member
+	// of a field tagged `readonly`, or of one tagged `createonly` when
+	// updating a resource.
+	ErrReadOnlyMember = errors.New("member is read-only")
+)
+
+// checkAccess enforces the `required`, `readonly` and `createonly` options
+// of the fields of the struct type t against the resource object data found
+// at loc, for the operation decoding is for.
+func (d *decoder) checkAccess(data *Node, t reflect.Type, loc location) error {
+	op := d.opts.operation
+	if data == nil || t.Kind() != reflect.Struct || (op != OperationAdd && op != OperationUpdate) {
+		return nil
+	}
+
+	for _, field := range modelFields(t) {
+		args := strings.Split(field.Tag.Get(annotationJSONAPI), annotationSeparator)
+		if len(args) < 3 {
+			continue
+		}
+
+		var sent bool
+		var pointer string
+		switch args[0] {
+		case annotationAttribute:
+			_, sent = data.Attributes[args[1]]
+			pointer = jsonPointer(loc.attributes, args[1])
+		case annotationRelation, annotationPolyRelation:
+			sent = hasLinkage(data.Relationships[args[1]])
+			pointer = jsonPointer(loc.node, "relationships", args[1])
+		default:
+			continue
+		}
+
+		var err error
+		switch {
+		case sent && hasTagOption(args, annotationReadOnly):
+			err = newAccessError(ErrReadOnlyMember, pointer, field.Name, http.StatusForbidden)
+		case sent && op == OperationUpdate && hasTagOption(args, annotationCreateOnly):
+			err = newAccessError(fmt.Errorf("%w: it can only be set when creating the resource", ErrReadOnlyMember), pointer, field.Name, http.StatusForbidden)
+		case !sent && op == OperationAdd && hasTagOption(args, annotationRequired):
+			err = newAccessError(ErrMissingMember, pointer, field.Name, http.StatusUnprocessableEntity)
+		}
+		if err != nil {
+			if err := d.report(err); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func newAccessError(err error, pointer, field string, status int) error {
+	return &UnmarshalError{
+		Pointer: pointer,
+		Field:   field,
+		Status:  status,
+		Err:     err,
+	}
+}
+
+// hasTagOption reports whether the jsonapi tag args holds option after the
+// member name.
+func hasTagOption(args []string, option string) bool {
+	if len(args) < 3 {
+		return false
+	}
+	for _, arg := range args[2:] {
+		if arg == option {
+			return true
+		}
+	}
+	return false
+}
+
+// withoutTagOption returns the jsonapi tag args without option.
+func withoutTagOption(args []string, option string) []string {
+	if !hasTagOption(args, option) {
+		return args
+	}
+
+	without := make([]string, 0, len(args)-1)
+	for i, arg := range args {
+		if i < 2 || arg != option {
+			without = append(without, arg)
+		}
+	}
+	return without
+}
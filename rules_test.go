@@ -0,0 +1,146 @@
+package jsonapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestUnmarshalPayload_accessRules(t *testing.T) {
+	for _, tc := range []struct {
+		desc     string
+		opts     []UnmarshalOption
+		payload  string
+		expected []*UnmarshalError
+	}{
+		{
+			desc:    "noOperation",
+			payload: `{"data": {"type": "accounts", "id": "1", "attributes": {"balance": 10}}}`,
+		},
+		{
+			desc: "create",
+			opts: []UnmarshalOption{ForOperation(OperationAdd)},
+			payload: `{"data": {"type": "accounts", "attributes": {"email": "a@b.c", "username": "a", "password": "secret"},
+				"relationships": {"owner": {"data": {"type": "users", "id": "1"}}}}}`,
+		},
+		{
+			desc:    "createMissing",
+			opts:    []UnmarshalOption{ForOperation(OperationAdd)},
+			payload: `{"data": {"type": "accounts", "attributes": {"username": "a"}}}`,
+			expected: []*UnmarshalError{
+				{Pointer: "/data/attributes/email", Field: "Email", Status: http.StatusUnprocessableEntity, Err: ErrMissingMember},
+				{Pointer: "/data/relationships/owner", Field: "Owner", Status: http.StatusUnprocessableEntity, Err: ErrMissingMember},
+			},
+		},
+		{
+			desc: "createReadOnly",
+			opts: []UnmarshalOption{ForOperation(OperationAdd)},
+			payload: `{"data": {"type": "accounts", "attributes": {"email": "a@b.c", "balance": 10},
+				"relationships": {"owner": {"data": {"type": "users", "id": "1"}}}}}`,
+			expected: []*UnmarshalError{
+				{Pointer: "/data/attributes/balance", Field: "Balance", Status: http.StatusForbidden, Err: ErrReadOnlyMember},
+			},
+		},
+		{
+			desc:    "update",
+			opts:    []UnmarshalOption{ForOperation(OperationUpdate)},
+			payload: `{"data": {"type": "accounts", "id": "1", "attributes": {"password": "secret"}}}`,
+		},
+		{
+			desc:    "updateCreateOnly",
+			opts:    []UnmarshalOption{ForOperation(OperationUpdate)},
+			payload: `{"data": {"type": "accounts", "id": "1", "attributes": {"username": "b"}}}`,
+			expected: []*UnmarshalError{
+				{Pointer: "/data/attributes/username", Field: "Username", Status: http.StatusForbidden, Err: ErrReadOnlyMember},
+			},
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			opts := append([]UnmarshalOption{CollectAllErrors()}, tc.opts...)
+			err := UnmarshalPayload(strings.NewReader(tc.payload), new(Account), opts...)
+			if len(tc.expected) == 0 {
+				if err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+
+			var errs UnmarshalErrors
+			if !errors.As(err, &errs) || len(errs) != len(tc.expected) {
+				t.Fatalf("Was expecting %d errors, got %v", len(tc.expected), err)
+			}
+			for i, expected := range tc.expected {
+				got := errs[i]
+				if got.Pointer != expected.Pointer || got.Field != expected.Field || got.Status != expected.Status || !errors.Is(got, expected.Err) {
+					t.Fatalf("Was expecting %+v, got %+v", expected, got)
+				}
+			}
+		})
+	}
+}
+
+func TestApplyPatch_accessRules(t *testing.T) {
+	account := &Account{ID: "1", Email: "a@b.c", Username: "a"}
+
+	payload := `{"data": {"type": "accounts", "id": "1", "attributes": {"username": "b"}}}`
+	_, err := ApplyPatch(strings.NewReader(payload), account)
+	if !errors.Is(err, ErrReadOnlyMember) {
+		t.Fatalf("Was expecting ErrReadOnlyMember, got %v", err)
+	}
+	if account.Username != "a" {
+		t.Fatalf("Was expecting the create-only username to be kept, got %q", account.Username)
+	}
+}
+
+func TestOperationUnmarshal_accessRules(t *testing.T) {
+	payload := `{"atomic:operations": [{"op": "add", "data": {"type": "accounts", "attributes": {"email": "a@b.c"}}}]}`
+
+	ops, _, err := UnmarshalOperations(strings.NewReader(payload), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = ops[0].Unmarshal(new(Account))
+
+	var unmarshalErr *UnmarshalError
+	if !errors.As(err, &unmarshalErr) || !errors.Is(err, ErrMissingMember) ||
+		unmarshalErr.Pointer != "/atomic:operations/0/data/relationships/owner" {
+		t.Fatalf("Was expecting the missing owner to be reported, got %v", err)
+	}
+}
+
+func TestMarshalPayload_writeOnly(t *testing.T) {
+	account := &Account{ID: "1", Email: "a@b.c", Password: "secret"}
+
+	out := bytes.NewBuffer(nil)
+	if err := MarshalPayload(out, account); err != nil {
+		t.Fatal(err)
+	}
+
+	var payload OnePayload
+	if err := json.Unmarshal(out.Bytes(), &payload); err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]interface{}{"email": "a@b.c", "balance": float64(0)}
+	if !reflect.DeepEqual(payload.Data.Attributes, expected) {
+		t.Fatalf("Was expecting attributes %v, got %v", expected, payload.Data.Attributes)
+	}
+	if _, ok := payload.Data.Relationships["owner"]; ok {
+		t.Fatal("Was expecting the omitempty owner to be left out")
+	}
+}
+
+func TestMarshalPatch_accessRules(t *testing.T) {
+	before := &Account{ID: "1", Email: "a@b.c", Username: "a", Balance: 1}
+	after := &Account{ID: "1", Email: "a@b.c", Username: "b", Balance: 2, Password: "secret"}
+
+	data := marshalPatchMap(t, before, after)
+	expected := map[string]interface{}{"password": "secret"}
+	if !reflect.DeepEqual(data["attributes"], expected) {
+		t.Fatalf("Was expecting attributes %v, got %v", expected, data["attributes"])
+	}
+}
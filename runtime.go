@@ -69,26 +69,33 @@ func (r *Runtime) shouldInstrument() bool {
 }
 
 // UnmarshalPayload has docs in request.go for UnmarshalPayload.
-func (r *Runtime) UnmarshalPayload(reader io.Reader, model interface{}) error {
+func (r *Runtime) UnmarshalPayload(reader io.Reader, model interface{}, opts ...UnmarshalOption) error {
 	return r.instrumentCall(UnmarshalStart, UnmarshalStop, func() error {
-		return UnmarshalPayload(reader, model)
+		return UnmarshalPayload(reader, model, opts...)
 	})
 }
 
 // UnmarshalManyPayload has docs in request.go for UnmarshalManyPayload.
-func (r *Runtime) UnmarshalManyPayload(reader io.Reader, kind reflect.Type) (elems []interface{}, err error) {
+func (r *Runtime) UnmarshalManyPayload(reader io.Reader, kind reflect.Type, opts ...UnmarshalOption) (elems []interface{}, err error) {
 	r.instrumentCall(UnmarshalStart, UnmarshalStop, func() error { //nolint:errcheck
-		elems, err = UnmarshalManyPayload(reader, kind)
+		elems, err = UnmarshalManyPayload(reader, kind, opts...)
 		return err
 	})
 
 	return
 }
 
+// UnmarshalManyPayloadEach has docs in stream.go for UnmarshalManyPayloadEach.
+func (r *Runtime) UnmarshalManyPayloadEach(reader io.Reader, kind reflect.Type, fn func(model interface{}) error, opts ...UnmarshalOption) error {
+	return r.instrumentCall(UnmarshalStart, UnmarshalStop, func() error {
+		return UnmarshalManyPayloadEach(reader, kind, fn, opts...)
+	})
+}
+
 // MarshalPayload has docs in response.go for MarshalPayload.
-func (r *Runtime) MarshalPayload(w io.Writer, model interface{}) error {
+func (r *Runtime) MarshalPayload(w io.Writer, model interface{}, opts ...MarshalOption) error {
 	return r.instrumentCall(MarshalStart, MarshalStop, func() error {
-		return MarshalPayload(w, model)
+		return MarshalPayload(w, model, opts...)
 	})
 }
 
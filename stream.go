@@ -0,0 +1,323 @@
+package jsonapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+)
+
+// UnmarshalManyPayloadEach decodes the resources in the "data" array of a
+// request document one at a time, passing each to fn as a new instance of the
+// struct type t points to, instead of returning them all at once like
+// UnmarshalManyPayload. Decoding stops at the first error returned by fn,
+// which UnmarshalManyPayloadEach then returns.
+//
+// Resources are read from in as they are needed, so memory use is bounded
+// by the size of a single resource and of the "included" array, as long as
+// "included" is known before "data" is read. That is the case when
+// "included" appears before "data" in the document, or when in is an
+// io.Seeker, in which case a first pass over the document indexes "included"
+// before seeking back to decode "data". It is also the case with
+// MaxIncludeDepth(0), under which "included" is not needed and is not
+// indexed, though it is still checked in DisallowUnknownMembers mode.
+// Otherwise every encoded resource of "data" is held in memory until the end
+// of the document, where "included" might still appear, and only then
+// decoded and passed to fn.
+//
+//	err := jsonapi.UnmarshalManyPayloadEach(r.Body, reflect.TypeOf(new(Blog)), func(model interface{}) error {
+//		return store.Save(model.(*Blog))
+//	})
+func UnmarshalManyPayloadEach(in io.Reader, t reflect.Type, fn func(model interface{}) error, opts ...UnmarshalOption) error {
+	s := &stream{d: newDecoder(opts), t: t, fn: fn}
+
+	if !s.d.includeDepthLeft() {
+		// Relationships are decoded from resource linkage alone, so the
+		// resources can be decoded without waiting for "included"
+		s.markIndexed()
+		s.linkageOnly = true
+	} else if rs, ok := in.(io.ReadSeeker); ok {
+		if err := s.indexIncluded(rs); err != nil {
+			return err
+		}
+	}
+
+	if err := s.decode(in); err != nil {
+		return err
+	}
+	return s.d.err()
+}
+
+// stream holds the state of a single UnmarshalManyPayloadEach call.
+type stream struct {
+	d  *decoder
+	t  reflect.Type
+	fn func(model interface{}) error
+
+	// indexed is set once the "included" resources have been indexed, or are
+	// known not to exist, so that resources can be decoded as they are read.
+	indexed bool
+	// pending holds the resources read before "included" was indexed.
+	pending []json.RawMessage
+	// linkageOnly is set under MaxIncludeDepth(0), when "included" is not
+	// indexed at all.
+	linkageOnly bool
+}
+
+// indexIncluded makes a first pass over the document to index its "included"
+// resources, then seeks back to where it started.
+func (s *stream) indexIncluded(rs io.ReadSeeker) error {
+	start, err := rs.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return err
+	}
+
+	dec := newNumberDecoder(rs)
+	if err := expectDelim(dec, '{'); err != nil {
+		return err
+	}
+	for dec.More() {
+		name, err := memberName(dec)
+		if err != nil {
+			return err
+		}
+
+		if name == "included" {
+			if err := s.decodeIncluded(dec); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := skipValue(dec); err != nil {
+			return err
+		}
+	}
+
+	// A document without "included" has nothing to index
+	s.markIndexed()
+
+	_, err = rs.Seek(start, io.SeekStart)
+	return err
+}
+
+// markIndexed records that the document has no "included" resources, unless
+// they have already been indexed.
+func (s *stream) markIndexed() {
+	if !s.indexed {
+		s.d.index(nil) //nolint:errcheck
+		s.indexed = true
+	}
+}
+
+// decode walks the document, decoding each resource in "data" as soon as
+// "included" has been indexed.
+func (s *stream) decode(in io.Reader) error {
+	dec := newNumberDecoder(in)
+	if err := expectDelim(dec, '{'); err != nil {
+		return err
+	}
+
+	indexedBefore := s.indexed
+	for dec.More() {
+		name, err := memberName(dec)
+		if err != nil {
+			return err
+		}
+
+		if s.d.opts.disallowUnknownMembers && !documentMembers[name] {
+			if err := s.d.report(newUnknownMemberError(name, jsonPointer("", name))); err != nil {
+				return err
+			}
+		}
+
+		switch {
+		case name == "data":
+			err = s.decodeData(dec)
+		case name == "included" && !indexedBefore:
+			err = s.decodeIncluded(dec)
+		case name == "included" && s.linkageOnly && s.d.opts.disallowUnknownMembers:
+			err = s.checkIncluded(dec)
+		default:
+			err = skipValue(dec)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if err := expectDelim(dec, '}'); err != nil {
+		return err
+	}
+
+	// Whatever was read before the end of the document can be decoded now
+	s.markIndexed()
+	for i, raw := range s.pending {
+		if err := s.each(raw, i); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// decodeData reads the resources of the "data" array.
+func (s *stream) decodeData(dec *json.Decoder) error {
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	if tok == nil {
+		return nil
+	}
+	if tok != json.Delim('[') {
+		return fmt.Errorf("jsonapi: expected \"data\" to be an array, got %v", tok)
+	}
+
+	for i := 0; dec.More(); i++ {
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); err != nil {
+			return err
+		}
+
+		if !s.indexed {
+			s.pending = append(s.pending, raw)
+			continue
+		}
+		if err := s.each(raw, i); err != nil {
+			return err
+		}
+	}
+
+	return expectDelim(dec, ']')
+}
+
+// decodeIncluded reads and indexes the resources of the "included" array.
+func (s *stream) decodeIncluded(dec *json.Decoder) error {
+	var raw []json.RawMessage
+	if err := dec.Decode(&raw); err != nil {
+		return err
+	}
+
+	included := make([]*Node, len(raw))
+	for i := range raw {
+		if err := s.checkMembers(raw[i], jsonPointer("/included", strconv.Itoa(i))); err != nil {
+			return err
+		}
+
+		included[i] = new(Node)
+		if err := newNumberDecoder(bytes.NewReader(raw[i])).Decode(included[i]); err != nil {
+			return err
+		}
+	}
+
+	s.indexed = true
+	return s.d.index(included)
+}
+
+// checkIncluded reads the resources of the "included" array one at a time,
+// only to report their unknown members.
+func (s *stream) checkIncluded(dec *json.Decoder) error {
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	if tok == nil {
+		return nil
+	}
+	if tok != json.Delim('[') {
+		return fmt.Errorf("jsonapi: expected \"included\" to be an array, got %v", tok)
+	}
+
+	for i := 0; dec.More(); i++ {
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); err != nil {
+			return err
+		}
+		if err := s.checkMembers(raw, jsonPointer("/included", strconv.Itoa(i))); err != nil {
+			return err
+		}
+	}
+
+	return expectDelim(dec, ']')
+}
+
+// each decodes the i-th resource of "data" and passes it to fn.
+func (s *stream) each(raw json.RawMessage, i int) error {
+	pointer := jsonPointer("/data", strconv.Itoa(i))
+	if err := s.checkMembers(raw, pointer); err != nil {
+		return err
+	}
+
+	node := new(Node)
+	if err := newNumberDecoder(bytes.NewReader(raw)).Decode(node); err != nil {
+		return err
+	}
+
+	// Resources of "data" are decoded independently, so that memory use does
+	// not grow with every model passed to fn
+	s.d.visited = nil
+
+	model, err := newModel(s.t, node, resourceAt(pointer), "")
+	if err != nil {
+		return err
+	}
+	if err := s.d.unmarshalPrimary(node, model, resourceAt(pointer)); err != nil {
+		return err
+	}
+	return s.fn(model.Interface())
+}
+
+// checkMembers reports unknown members of the resource object raw in
+// DisallowUnknownMembers mode.
+func (s *stream) checkMembers(raw json.RawMessage, pointer string) error {
+	if !s.d.opts.disallowUnknownMembers {
+		return nil
+	}
+	return s.d.checkResourceMembers(raw, pointer)
+}
+
+// memberName reads the name of the next member of an object.
+func memberName(dec *json.Decoder) (string, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return "", err
+	}
+	name, ok := tok.(string)
+	if !ok {
+		return "", fmt.Errorf("jsonapi: expected a member name, got %v", tok)
+	}
+	return name, nil
+}
+
+// expectDelim reads the next token, which must be delim.
+func expectDelim(dec *json.Decoder, delim json.Delim) error {
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	if tok != delim {
+		return fmt.Errorf("jsonapi: expected %v, got %v", delim, tok)
+	}
+	return nil
+}
+
+// skipValue reads past the next value without holding all of it in memory.
+func skipValue(dec *json.Decoder) error {
+	depth := 0
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return err
+		}
+
+		switch tok {
+		case json.Delim('{'), json.Delim('['):
+			depth++
+		case json.Delim('}'), json.Delim(']'):
+			depth--
+		}
+		if depth == 0 {
+			return nil
+		}
+	}
+}
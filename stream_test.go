@@ -0,0 +1,215 @@
+package jsonapi
+
+import (
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const streamData = `"data": [
+	{"type": "posts", "id": "1", "attributes": {"title": "First"},
+	 "relationships": {"latest_comment": {"data": {"type": "comments", "id": "10"}}}},
+	{"type": "posts", "id": "2", "attributes": {"title": "Second"}}
+]`
+
+const streamIncluded = `"included": [
+	{"type": "comments", "id": "10", "attributes": {"body": "Hello"}}
+]`
+
+// onlyReader hides any io.Seeker implementation of the reader it wraps.
+type onlyReader struct {
+	io.Reader
+}
+
+func collectPosts(t *testing.T, in io.Reader, opts ...UnmarshalOption) ([]*Post, error) {
+	t.Helper()
+
+	var posts []*Post
+	err := UnmarshalManyPayloadEach(in, reflect.TypeOf(new(Post)), func(model interface{}) error {
+		posts = append(posts, model.(*Post))
+		return nil
+	}, opts...)
+	return posts, err
+}
+
+func TestUnmarshalManyPayloadEach(t *testing.T) {
+	for _, tc := range []struct {
+		desc string
+		in   io.Reader
+	}{
+		{
+			desc: "includedFirst",
+			in:   onlyReader{strings.NewReader(`{` + streamIncluded + `,` + streamData + `}`)},
+		},
+		{
+			desc: "includedLast",
+			in:   onlyReader{strings.NewReader(`{` + streamData + `,` + streamIncluded + `}`)},
+		},
+		{
+			desc: "includedLast_seeker",
+			in:   strings.NewReader(`{` + streamData + `,` + streamIncluded + `}`),
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			posts, err := collectPosts(t, tc.in)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(posts) != 2 {
+				t.Fatalf("Was expecting 2 posts, got %d", len(posts))
+			}
+			if posts[0].ID != 1 || posts[0].Title != "First" || posts[1].ID != 2 || posts[1].Title != "Second" {
+				t.Fatalf("Posts were not decoded in order: %+v, %+v", posts[0], posts[1])
+			}
+			if posts[0].LatestComment == nil || posts[0].LatestComment.Body != "Hello" {
+				t.Fatalf("Was expecting the latest comment to be resolved from included, got %+v", posts[0].LatestComment)
+			}
+		})
+	}
+}
+
+func TestUnmarshalManyPayloadEach_streams(t *testing.T) {
+	r, w := io.Pipe()
+	received := make(chan *Post)
+	done := make(chan error)
+
+	go func() {
+		done <- UnmarshalManyPayloadEach(r, reflect.TypeOf(new(Post)), func(model interface{}) error {
+			received <- model.(*Post)
+			return nil
+		})
+	}()
+
+	// The first resource must be decoded before the rest of the document has
+	// been written.
+	io.WriteString(w, `{`+streamIncluded+`, "data": [{"type": "posts", "id": "1"}`) //nolint:errcheck
+	if post := <-received; post.ID != 1 {
+		t.Fatalf("Was expecting post 1, got %+v", post)
+	}
+
+	io.WriteString(w, `, {"type": "posts", "id": "2"}]}`) //nolint:errcheck
+	if post := <-received; post.ID != 2 {
+		t.Fatalf("Was expecting post 2, got %+v", post)
+	}
+	w.Close()
+
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestUnmarshalManyPayloadEach_noIncludeDepth(t *testing.T) {
+	r, w := io.Pipe()
+	received := make(chan *Post)
+	done := make(chan error)
+
+	go func() {
+		done <- UnmarshalManyPayloadEach(r, reflect.TypeOf(new(Post)), func(model interface{}) error {
+			received <- model.(*Post)
+			return nil
+		}, MaxIncludeDepth(0))
+	}()
+
+	// Without "included" known yet, the first resource must still be decoded
+	// before the rest of the document has been written.
+	io.WriteString(w, `{"data": [{"type": "posts", "id": "1", "relationships": {"latest_comment": {"data": {"type": "comments", "id": "10"}}}}`) //nolint:errcheck
+	post := <-received
+	if post.ID != 1 {
+		t.Fatalf("Was expecting post 1, got %+v", post)
+	}
+	if post.LatestComment == nil || post.LatestComment.ID != 10 || post.LatestComment.Body != "" {
+		t.Fatalf("Was expecting the latest comment to be decoded from its linkage, got %+v", post.LatestComment)
+	}
+
+	io.WriteString(w, `],`+streamIncluded+`}`) //nolint:errcheck
+	w.Close()
+
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestUnmarshalManyPayloadEach_callbackError(t *testing.T) {
+	stop := errors.New("stop")
+	calls := 0
+
+	err := UnmarshalManyPayloadEach(strings.NewReader(`{`+streamData+`}`), reflect.TypeOf(new(Post)), func(model interface{}) error {
+		calls++
+		return stop
+	})
+	if !errors.Is(err, stop) {
+		t.Fatalf("Was expecting the callback's error, got %v", err)
+	}
+	if calls != 1 {
+		t.Fatalf("Was expecting decoding to stop after the first resource, got %d calls", calls)
+	}
+}
+
+func TestUnmarshalManyPayloadEach_collectAllErrors(t *testing.T) {
+	in := strings.NewReader(`{"data": [
+		{"type": "posts", "id": "1", "attributes": {"title": 1}},
+		{"type": "posts", "id": "2", "attributes": {"title": "Second"}},
+		{"type": "posts", "id": "3", "attributes": {"body": 3}}
+	]}`)
+
+	posts, err := collectPosts(t, in, CollectAllErrors())
+
+	var errs UnmarshalErrors
+	if !errors.As(err, &errs) {
+		t.Fatalf("Was expecting UnmarshalErrors, got %T: %v", err, err)
+	}
+	if len(errs) != 2 || errs[0].Pointer != "/data/0/attributes/title" || errs[1].Pointer != "/data/2/attributes/body" {
+		t.Fatalf("Unexpected errors: %v", errs)
+	}
+	if len(posts) != 3 {
+		t.Fatalf("Was expecting every post to be passed to the callback, got %d", len(posts))
+	}
+}
+
+func TestUnmarshalManyPayloadEach_disallowUnknownMembers(t *testing.T) {
+	in := onlyReader{strings.NewReader(`{` + streamData + `, "extra": {"a": [1]}}`)}
+
+	_, err := collectPosts(t, in, DisallowUnknownMembers())
+	if !errors.Is(err, ErrUnknownMember) {
+		t.Fatalf("Was expecting %v, got %v", ErrUnknownMember, err)
+	}
+	assertUnmarshalErrorPointer(t, err, "/extra")
+}
+
+func TestUnmarshalManyPayloadEach_disallowUnknownMembersInIncluded(t *testing.T) {
+	included := `"included": [
+		{"type": "comments", "id": "10"},
+		{"type": "comments", "id": "11", "extra": true}
+	]`
+
+	for _, tc := range []struct {
+		desc string
+		in   io.Reader
+		opts []UnmarshalOption
+	}{
+		{
+			desc: "includedLast",
+			in:   onlyReader{strings.NewReader(`{` + streamData + `,` + included + `}`)},
+		},
+		{
+			desc: "includedLast_seeker",
+			in:   strings.NewReader(`{` + streamData + `,` + included + `}`),
+		},
+		{
+			desc: "noIncludeDepth",
+			in:   onlyReader{strings.NewReader(`{` + streamData + `,` + included + `}`)},
+			opts: []UnmarshalOption{MaxIncludeDepth(0)},
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			_, err := collectPosts(t, tc.in, append(tc.opts, DisallowUnknownMembers())...)
+			if !errors.Is(err, ErrUnknownMember) {
+				t.Fatalf("Was expecting %v, got %v", ErrUnknownMember, err)
+			}
+			assertUnmarshalErrorPointer(t, err, "/included/1/extra")
+		})
+	}
+}
@@ -0,0 +1,167 @@
+package jsonapi
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// timeFormat describes how a time attribute is represented in a document,
+// as given by the options of its attr annotation.
+type timeFormat struct {
+	// layout is the time.Parse layout of a string timestamp, or empty for a
+	// numeric unix timestamp.
+	layout string
+	// unit is the precision of a numeric unix timestamp.
+	unit time.Duration
+	// keepOffset encodes times in their own offset rather than in UTC.
+	keepOffset bool
+	// err is returned when a value cannot be parsed with this format.
+	err error
+}
+
+// parseTimeFormat reads the time options from the arguments of an attr
+// annotation. Without any, times are unix timestamps in seconds.
+func parseTimeFormat(args []string) timeFormat {
+	f := timeFormat{unit: time.Second, err: ErrInvalidTime}
+	if len(args) < 3 {
+		return f
+	}
+
+	for _, arg := range args[2:] {
+		switch {
+		case arg == annotationISO8601:
+			f.layout, f.err = iso8601TimeFormat, ErrInvalidISO8601
+		case arg == annotationRFC3339:
+			f.layout, f.err = time.RFC3339, ErrInvalidRFC3339
+		case arg == annotationRFC3339Nano:
+			f.layout, f.err = time.RFC3339Nano, ErrInvalidRFC3339
+		case arg == annotationDate:
+			f.layout, f.err = dateTimeFormat, ErrInvalidTimeLayout
+		case strings.HasPrefix(arg, annotationLayout):
+			f.layout, f.err = strings.TrimPrefix(arg, annotationLayout), ErrInvalidTimeLayout
+		case arg == annotationUnixMilli:
+			f.unit = time.Millisecond
+		case arg == annotationUnixNano:
+			f.unit = time.Nanosecond
+		case arg == annotationKeepOffset:
+			f.keepOffset = true
+		}
+	}
+
+	return f
+}
+
+// attributeOptions holds the options an attr annotation may take after its
+// name, other than `layout=`.
+var attributeOptions = map[string]bool{
+	annotationOmitEmpty:   true,
+	annotationISO8601:     true,
+	annotationRFC3339:     true,
+	annotationRFC3339Nano: true,
+	annotationDate:        true,
+	annotationUnixMilli:   true,
+	annotationUnixNano:    true,
+	annotationKeepOffset:  true,
+	annotationString:      true,
+	annotationRequired:    true,
+	annotationReadOnly:    true,
+	annotationCreateOnly:  true,
+	annotationWriteOnly:   true,
+}
+
+// checkTimeLayout rejects the arguments of an attr annotation whose
+// `layout=` option is followed by an argument that is not an option. As
+// annotations are split on commas, that is what a layout holding a comma,
+// such as time.RFC1123, is cut into, and it would otherwise be silently
+// truncated.
+func checkTimeLayout(args []string) error {
+	if len(args) < 3 {
+		return nil
+	}
+
+	layout := false
+	for _, arg := range args[2:] {
+		switch {
+		case strings.HasPrefix(arg, annotationLayout):
+			layout = true
+		case layout && !attributeOptions[arg]:
+			return fmt.Errorf("%w: the time layout of %q cannot contain a comma", ErrBadJSONAPIStructTag, args[1])
+		}
+	}
+	return nil
+}
+
+// format returns the document representation of t.
+func (f timeFormat) format(t time.Time) interface{} {
+	// The iso8601 layout ends in a literal Z, so it is always UTC
+	if !f.keepOffset || f.layout == iso8601TimeFormat {
+		t = t.UTC()
+	}
+
+	if f.layout != "" {
+		return t.Format(f.layout)
+	}
+
+	switch f.unit {
+	case time.Millisecond:
+		return t.UnixMilli()
+	case time.Nanosecond:
+		return t.UnixNano()
+	default:
+		return t.Unix()
+	}
+}
+
+// parse converts the document representation of a time into a time.Time.
+func (f timeFormat) parse(attribute interface{}) (time.Time, error) {
+	v := reflect.ValueOf(attribute)
+
+	if f.layout != "" {
+		if v.Kind() != reflect.String {
+			return time.Time{}, f.err
+		}
+
+		t, err := time.Parse(f.layout, v.String())
+		if err != nil {
+			return time.Time{}, f.err
+		}
+		return t, nil
+	}
+
+	// Unix timestamps must be whole numbers that fit an int64, rather than be
+	// truncated
+	var at int64
+	var err error
+	if n, ok := attribute.(json.Number); ok {
+		at, err = parseInteger(n.String())
+	} else {
+		switch v.Kind() {
+		case reflect.Float64:
+			at, err = parseInteger(strconv.FormatFloat(v.Float(), 'g', -1, 64))
+		case reflect.Int:
+			at = v.Int()
+		default:
+			return time.Time{}, f.err
+		}
+	}
+	if errors.Is(err, ErrNumberOutOfRange) {
+		return time.Time{}, fmt.Errorf("%w: %v as a unix timestamp", ErrNumberOutOfRange, attribute)
+	}
+	if err != nil {
+		return time.Time{}, f.err
+	}
+
+	switch f.unit {
+	case time.Millisecond:
+		return time.UnixMilli(at), nil
+	case time.Nanosecond:
+		return time.Unix(0, at), nil
+	default:
+		return time.Unix(at, 0), nil
+	}
+}
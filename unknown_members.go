@@ -0,0 +1,137 @@
+package jsonapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+)
+
+var (
+	// documentMembers are the top-level members of a request document.
+	documentMembers = map[string]bool{
+		"data":     true,
+		"included": true,
+		"links":    true,
+		"meta":     true,
+		"jsonapi":  true,
+	}
+
+	// resourceMembers are the members of a resource object, including the
+	// `client-id` member supported by this package.
+	resourceMembers = map[string]bool{
+		"type":          true,
+		"id":            true,
+		"lid":           true,
+		"client-id":     true,
+		"attributes":    true,
+		"relationships": true,
+		"links":         true,
+		"meta":          true,
+	}
+
+	// relationshipMembers are the members of a relationship object.
+	relationshipMembers = map[string]bool{
+		"data":  true,
+		"links": true,
+		"meta":  true,
+	}
+)
+
+func newUnknownMemberError(name, pointer string) error {
+	return &UnmarshalError{
+		Pointer: pointer,
+		Status:  http.StatusBadRequest,
+		Err:     fmt.Errorf("%w %q", ErrUnknownMember, name),
+	}
+}
+
+// checkDocumentMembers reports top-level members of the request document, and
+// members of the resource objects in its "data" and "included", that the spec
+// does not define.
+func (d *decoder) checkDocumentMembers(document map[string]json.RawMessage) error {
+	for _, name := range sortedKeys(document) {
+		if documentMembers[name] {
+			continue
+		}
+		if err := d.report(newUnknownMemberError(name, jsonPointer("", name))); err != nil {
+			return err
+		}
+	}
+
+	if err := d.checkResourceMembers(document["data"], "/data"); err != nil {
+		return err
+	}
+	return d.checkResourceMembers(document["included"], "/included")
+}
+
+// checkResourceMembers reports unknown members of the resource object, or
+// array of resource objects, found at pointer.
+func (d *decoder) checkResourceMembers(raw json.RawMessage, pointer string) error {
+	var resources []map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &resources); err != nil {
+		var resource map[string]json.RawMessage
+		if err := json.Unmarshal(raw, &resource); err != nil {
+			// Not a resource object; decoding will report it.
+			return nil
+		}
+		return checkMembers(d, resource, resourceMembers, pointer)
+	}
+
+	for i, resource := range resources {
+		if err := checkMembers(d, resource, resourceMembers, jsonPointer(pointer, fmt.Sprint(i))); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkNodeMembers reports attributes and relationships of a resource object
+// that have no matching field on the model it is being decoded into.
+func (d *decoder) checkNodeMembers(data *Node, loc location, attributes, relationships map[string]bool) error {
+	if err := checkMembers(d, data.Attributes, attributes, loc.attributes); err != nil {
+		return err
+	}
+
+	for _, name := range sortedKeys(data.Relationships) {
+		pointer := jsonPointer(loc.node, "relationships", name)
+		if !relationships[name] {
+			if err := d.report(newUnknownMemberError(name, pointer)); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if relationship, ok := data.Relationships[name].(map[string]interface{}); ok {
+			if err := checkMembers(d, relationship, relationshipMembers, pointer); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// checkMembers reports the members of object that are not in known.
+func checkMembers[V any](d *decoder, object map[string]V, known map[string]bool, pointer string) error {
+	for _, name := range sortedKeys(object) {
+		if known[name] {
+			continue
+		}
+		if err := d.report(newUnknownMemberError(name, jsonPointer(pointer, name))); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sortedKeys returns the keys of m in a stable order, so that unknown members
+// are always reported in the same order.
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,156 @@
+package jsonapi
+
+import (
+	"errors"
+	"net/http"
+	"reflect"
+	"strings"
+)
+
+// Validator is implemented by models that check their own values once they
+// have been decoded from a request document. The Unmarshal functions call
+// ValidateJSONAPI on each model of the primary data after populating it, and
+// report the error it returns as an *UnmarshalError with a 422 Unprocessable
+// Entity status, so that validation can live next to the model.
+//
+//	func (b *Blog) ValidateJSONAPI() error {
+//		var errs jsonapi.FieldErrors
+//		if b.Title == "" {
+//			errs = append(errs, &jsonapi.FieldError{Field: "Title", Err: errors.New("must not be blank")})
+//		}
+//		return errs
+//	}
+//
+// A *FieldError, or each of FieldErrors, is located at the member of the
+// resource object that its field is decoded from, such as
+// /data/attributes/title; any other error is located at the resource object
+// itself. Models of included resources are not validated.
+type Validator interface {
+	ValidateJSONAPI() error
+}
+
+// FieldError reports that the value of a model's field is invalid. Field is
+// the name of the Go struct field, which is used to locate the error at the
+// `attr`, `relation` or `primary` member it is decoded from.
+//
+// An *ErrorObject can be given as Err to control the error object the error
+// is converted to, such as to set its Code.
+type FieldError struct {
+	Field string
+	Err   error
+}
+
+// Error implements the `Error` interface.
+func (e *FieldError) Error() string {
+	return e.Field + ": " + e.Err.Error()
+}
+
+// Unwrap returns the underlying error.
+func (e *FieldError) Unwrap() error {
+	return e.Err
+}
+
+// FieldErrors is a list of invalid fields, which is treated as no error at
+// all when empty.
+type FieldErrors []*FieldError
+
+// Error implements the `Error` interface.
+func (e FieldErrors) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// unmarshalPrimary checks the access rules of model's fields against a
+// resource object of the primary data, decodes it into model, and then
+// validates model if it is a Validator. In CollectAllErrors mode, a model that
+// could not be fully decoded is not validated.
+func (d *decoder) unmarshalPrimary(data *Node, model reflect.Value, loc location) error {
+	reported := len(d.errs)
+	if err := d.checkAccess(data, indirectType(model.Type()), loc); err != nil {
+		return err
+	}
+	if err := d.unmarshalResource(data, model, loc); err != nil {
+		return err
+	}
+	if len(d.errs) > reported {
+		return nil
+	}
+
+	validator, ok := model.Interface().(Validator)
+	if !ok {
+		return nil
+	}
+	if err := newValidationError(validator.ValidateJSONAPI(), model.Type(), loc); err != nil {
+		return d.report(err)
+	}
+	return nil
+}
+
+// newValidationError locates err, returned by the ValidateJSONAPI method of
+// a model of type t decoded from loc, within the request document. It
+// returns nil for a nil error or an empty FieldErrors.
+func newValidationError(err error, t reflect.Type, loc location) error {
+	if err == nil {
+		return nil
+	}
+
+	var fieldErrs FieldErrors
+	var fieldErr *FieldError
+	switch {
+	case errors.As(err, &fieldErrs):
+		if len(fieldErrs) == 0 {
+			return nil
+		}
+	case errors.As(err, &fieldErr):
+		fieldErrs = FieldErrors{fieldErr}
+	default:
+		return &UnmarshalError{
+			Pointer: loc.node,
+			Status:  http.StatusUnprocessableEntity,
+			Err:     err,
+		}
+	}
+
+	errs := make(UnmarshalErrors, len(fieldErrs))
+	for i, fieldErr := range fieldErrs {
+		errs[i] = &UnmarshalError{
+			Pointer: fieldPointer(indirectType(t), fieldErr.Field, loc),
+			Field:   fieldErr.Field,
+			Status:  http.StatusUnprocessableEntity,
+			Err:     fieldErr.Err,
+		}
+	}
+	if len(errs) == 1 {
+		return errs[0]
+	}
+	return errs
+}
+
+// fieldPointer returns a JSON Pointer to the member the named field of the
+// struct type t is decoded from, for a resource object at loc. A field that
+// is not decoded from a member of its own is located at the resource object.
+func fieldPointer(t reflect.Type, name string, loc location) string {
+	for _, field := range modelFields(t) {
+		if field.Name != name {
+			continue
+		}
+
+		args := strings.Split(field.Tag.Get(annotationJSONAPI), annotationSeparator)
+		if len(args) < 2 {
+			break
+		}
+		switch args[0] {
+		case annotationPrimary:
+			return jsonPointer(loc.node, "id")
+		case annotationAttribute:
+			return jsonPointer(loc.attributes, args[1])
+		case annotationRelation, annotationPolyRelation:
+			return jsonPointer(loc.node, "relationships", args[1])
+		}
+		break
+	}
+	return loc.node
+}
@@ -0,0 +1,151 @@
+package jsonapi
+
+import (
+	"errors"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestUnmarshalPayload_validator(t *testing.T) {
+	for _, tc := range []struct {
+		desc     string
+		payload  string
+		pointers []string
+	}{
+		{
+			desc:    "valid",
+			payload: `{"data": {"type": "drafts", "id": "1", "attributes": {"title": "Draft", "words": 3}}}`,
+		},
+		{
+			desc:     "oneField",
+			payload:  `{"data": {"type": "drafts", "id": "1", "attributes": {"words": 3}}}`,
+			pointers: []string{"/data/attributes/title"},
+		},
+		{
+			desc:     "manyFields",
+			payload:  `{"data": {"type": "drafts", "id": "1", "attributes": {"words": -1}}}`,
+			pointers: []string{"/data/attributes/title", "/data/attributes/words"},
+		},
+		{
+			desc:     "resource",
+			payload:  `{"data": {"type": "drafts", "id": "rejected", "attributes": {"title": "Draft"}}}`,
+			pointers: []string{"/data"},
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			err := UnmarshalPayload(strings.NewReader(tc.payload), new(Draft))
+			if len(tc.pointers) == 0 {
+				if err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+
+			var errs UnmarshalErrors
+			var single *UnmarshalError
+			if !errors.As(err, &errs) {
+				if !errors.As(err, &single) {
+					t.Fatalf("Was expecting unmarshal errors, got %v", err)
+				}
+				errs = UnmarshalErrors{single}
+			}
+
+			var pointers []string
+			for _, e := range errs {
+				if e.Status != http.StatusUnprocessableEntity {
+					t.Fatalf("Was expecting a 422 status, got %d", e.Status)
+				}
+				pointers = append(pointers, e.Pointer)
+			}
+			if !reflect.DeepEqual(pointers, tc.pointers) {
+				t.Fatalf("Was expecting pointers %v, got %v", tc.pointers, pointers)
+			}
+		})
+	}
+}
+
+func TestUnmarshalPayload_validatorErrorObjects(t *testing.T) {
+	payload := `{"data": {"type": "drafts", "id": "1", "attributes": {"words": -1}}}`
+
+	var errs UnmarshalErrors
+	if err := UnmarshalPayload(strings.NewReader(payload), new(Draft)); !errors.As(err, &errs) {
+		t.Fatalf("Was expecting unmarshal errors, got %v", err)
+	}
+
+	expected := []*ErrorObject{
+		{
+			Title:  "Unprocessable Entity",
+			Detail: "must not be blank",
+			Status: "422",
+			Source: &ErrorSource{Pointer: "/data/attributes/title"},
+		},
+		{
+			Title:  "Unprocessable Entity",
+			Detail: "must not be negative",
+			Status: "422",
+			Code:   "negative",
+			Source: &ErrorSource{Pointer: "/data/attributes/words"},
+		},
+	}
+	if objs := errs.ErrorObjects(); !reflect.DeepEqual(objs, expected) {
+		t.Fatalf("Was expecting error objects %+v, got %+v", expected, objs)
+	}
+}
+
+func TestUnmarshalManyPayload_validator(t *testing.T) {
+	payload := `{"data": [
+		{"type": "drafts", "id": "1", "attributes": {"title": "Draft"}},
+		{"type": "drafts", "id": "2", "attributes": {"title": ""}}
+	]}`
+
+	_, err := UnmarshalManyPayload(strings.NewReader(payload), reflect.TypeOf(new(Draft)))
+
+	var unmarshalErr *UnmarshalError
+	if !errors.As(err, &unmarshalErr) || unmarshalErr.Pointer != "/data/1/attributes/title" {
+		t.Fatalf("Was expecting an error at /data/1/attributes/title, got %v", err)
+	}
+}
+
+func TestUnmarshalPayload_validatorSkippedAfterErrors(t *testing.T) {
+	payload := `{"data": {"type": "drafts", "id": "1", "attributes": {"words": "many"}}}`
+
+	err := UnmarshalPayload(strings.NewReader(payload), new(Draft), CollectAllErrors())
+
+	var errs UnmarshalErrors
+	if !errors.As(err, &errs) || len(errs) != 1 || errs[0].Pointer != "/data/attributes/words" || errs[0].Status != http.StatusBadRequest {
+		t.Fatalf("Was expecting only the decoding error, got %v", err)
+	}
+}
+
+func TestApplyPatch_validator(t *testing.T) {
+	draft := &Draft{ID: "1", Title: "Draft"}
+
+	payload := `{"data": {"type": "drafts", "id": "1", "attributes": {"title": ""}}}`
+	_, err := ApplyPatch(strings.NewReader(payload), draft)
+
+	var unmarshalErr *UnmarshalError
+	if !errors.As(err, &unmarshalErr) || unmarshalErr.Pointer != "/data/attributes/title" {
+		t.Fatalf("Was expecting the patched model to be validated, got %v", err)
+	}
+}
+
+func TestFieldPointer(t *testing.T) {
+	for _, tc := range []struct {
+		field    string
+		expected string
+	}{
+		{field: "ID", expected: "/data/0/id"},
+		{field: "Title", expected: "/data/0/attributes/title"},
+		{field: "LatestComment", expected: "/data/0/relationships/latest_comment"},
+		{field: "Links", expected: "/data/0"},
+		{field: "Missing", expected: "/data/0"},
+	} {
+		t.Run(tc.field, func(t *testing.T) {
+			if got := fieldPointer(reflect.TypeOf(Post{}), tc.field, resourceAt("/data/0")); got != tc.expected {
+				t.Fatalf("Was expecting %s, got %s", tc.expected, got)
+			}
+		})
+	}
+}